@@ -1,22 +1,127 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
 
 	"github.com/humans-group/cimp/lib/cimp"
 	"github.com/humans-group/cimp/lib/tree"
 )
 
+const (
+	importMode = "import"
+	exportMode = "export"
+	diffMode   = "diff"
+)
+
+const formatUsage = "File format: json, yaml, edn, toml, hcl, properties, env, ini, xml, json5, consul (format of `consul kv export`). If empty - got from extension. Default: yaml"
+
+const (
+	xmlAttrPrefixUsage   = "Prefix of keys which are XML attributes"
+	yamlDocumentKeyUsage = "Key of multi-document YAML which value is used as sub-prefix of the document. If empty - documents are merged in order"
+	namingUsage          = "Naming of keys: snake, verbatim, kebab, camel. If empty - snake, but keys of consul format are kept as is. Custom naming functions are available only in the library"
+	mergeBranchesUsage   = "Strategy of merging branches of several config-files: replace, append, key (merge elements by key field)"
+	mergeKeyUsage        = "Field of branch elements which identifies them for key strategy of merging"
+)
+
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
 func main() {
-	pathRaw := flag.String("p", "./config.yaml", "Path to config-file which should be imported")
-	formatRaw := flag.String("f", "", "File format: json, yaml, edn. If empty - got from extension. Default: yaml")
-	consulEndpoint := flag.String("c", "127.0.0.1:8500", "Consul endpoint in format `address:port`")
-	prefixRaw := flag.String("pref", "", "Prefix for all keys")
+	mode := importMode
+	args := os.Args[1:]
+	// mode is optional for backward compatibility, import is used by default
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+
+	switch mode {
+	case importMode:
+		runImport(args)
+	case exportMode:
+		runExport(args)
+	case diffMode:
+		runDiff(args)
+	default:
+		panic(fmt.Sprintf("unknown mode %q, use one of: %s, %s, %s", mode, importMode, exportMode, diffMode))
+	}
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet(importMode, flag.ExitOnError)
+	paths := newPathsFlag("./config.yaml")
+	flags.Var(paths, "p", "Path to config-file which should be imported. If it's repeated - files are merged in order")
+	formatRaw := flags.String("f", "", formatUsage)
+	storageURL := flags.String("c", "127.0.0.1:8500", "Storage URL: consul://address:port[?nulls=empty|skip|delete], etcd://address:port, file:///path/to/file.yaml, dir:///path/to/directory, mem://. Consul endpoint in format `address:port` is allowed too")
+	prefixRaw := flags.String("pref", "", "Prefix for all keys")
+	dryRun := flags.Bool("dry-run", false, "Print planned consul transactions without executing them")
+	prune := flags.Bool("prune", false, "Delete keys under the prefix which are absent in config-file, after all keys are saved; it isn't atomic if more than one transaction is needed")
+	pruneLimit := flags.Int("prune-limit", 100, "Maximum count of keys which can be deleted by prune")
+	xmlAttrPrefix := flags.String("xml-attr-prefix", tree.DefaultXMLConfig.AttrPrefix, xmlAttrPrefixUsage)
+	yamlDocumentKey := flags.String("yaml-doc-key", "", yamlDocumentKeyUsage)
+	naming := flags.String("naming", "", namingUsage)
+	mergeBranches := flags.String("merge-branches", string(tree.BranchReplace), mergeBranchesUsage)
+	mergeKey := flags.String("merge-key", "", mergeKeyUsage)
 
-	flag.Parse()
-	if pathRaw == nil || formatRaw == nil || consulEndpoint == nil || prefixRaw == nil {
+	check(flags.Parse(args))
+	if formatRaw == nil || storageURL == nil || prefixRaw == nil || dryRun == nil || prune == nil || pruneLimit == nil ||
+		xmlAttrPrefix == nil || yamlDocumentKey == nil || naming == nil || mergeBranches == nil || mergeKey == nil {
+		panic("Impossible! Flags with defaults can't be nil")
+	}
+
+	mergeOpts := tree.MergeOptions{Branches: tree.BranchStrategy(*mergeBranches), KeyField: *mergeKey}
+	kv := readKV(paths.values, *formatRaw, *prefixRaw, *naming, mergeOpts,
+		cimp.WithXMLConfig(xmlConfig(*xmlAttrPrefix)), cimp.WithYAMLDocumentKey(*yamlDocumentKey))
+
+	storage, err := cimp.NewStorageFromURL(*storageURL)
+	check(err)
+
+	if *dryRun || *prune {
+		consulStorage, ok := storage.(*cimp.ConsulStorage)
+		if !ok {
+			panic(fmt.Sprintf("dry-run and prune are supported only by consul storage, not %T", storage))
+		}
+
+		if *dryRun {
+			var batches []api.TxnOps
+			if *prune {
+				batches, err = consulStorage.PlanSync(kv, *pruneLimit)
+			} else {
+				batches, err = consulStorage.Plan(kv)
+			}
+			check(err)
+			printPlan(batches)
+			return
+		}
+
+		check(consulStorage.Sync(kv, *pruneLimit))
+		return
+	}
+
+	check(storage.Save(kv))
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet(exportMode, flag.ExitOnError)
+	pathRaw := flags.String("p", "./config.yaml", "Path to config-file which should be written")
+	formatRaw := flags.String("f", "", formatUsage)
+	storageURL := flags.String("c", "127.0.0.1:8500", "Storage URL: consul://address:port, etcd://address:port, file:///path/to/file.yaml, dir:///path/to/directory, mem://. Consul endpoint in format `address:port` is allowed too")
+	prefixRaw := flags.String("pref", "", "Prefix of keys which should be exported")
+	indent := flags.Int("indent", 2, "Indent spaces of written file")
+	xmlAttrPrefix := flags.String("xml-attr-prefix", tree.DefaultXMLConfig.AttrPrefix, xmlAttrPrefixUsage)
+
+	check(flags.Parse(args))
+	if pathRaw == nil || formatRaw == nil || storageURL == nil || prefixRaw == nil || indent == nil ||
+		xmlAttrPrefix == nil {
 		panic("Impossible! Flags with defaults can't be nil")
 	}
 
@@ -26,20 +131,168 @@ func main() {
 	format, err := cimp.NewFormat(*formatRaw, path)
 	check(err)
 
-	cfgRaw, err := ioutil.ReadFile(path)
+	storage, err := cimp.NewStorageFromURL(*storageURL)
 	check(err)
 
-	kv := cimp.NewKV(tree.New())
-	unmarshaler := cimp.NewUnmarshaler(kv, format)
-	err = unmarshaler.Unmarshal(cfgRaw)
+	kv, err := storage.Load(*prefixRaw)
 	check(err)
 
-	kv.AddPrefix(*prefixRaw)
+	cfgRaw, err := cimp.NewMarshaler(kv, format, *indent, cimp.WithXMLConfig(xmlConfig(*xmlAttrPrefix))).Marshal()
+	check(err)
 
-	storage, err := cimp.NewStorage(cimp.Config{Address: *consulEndpoint})
+	check(ioutil.WriteFile(path, cfgRaw, 0644))
+}
+
+func runDiff(args []string) {
+	flags := flag.NewFlagSet(diffMode, flag.ExitOnError)
+	paths := newPathsFlag("./config.yaml")
+	flags.Var(paths, "p", "Path to config-file which should be compared with consul. If it's repeated - files are merged in order")
+	formatRaw := flags.String("f", "", formatUsage)
+	storageURL := flags.String("c", "127.0.0.1:8500", "Storage URL: consul://address:port, etcd://address:port, file:///path/to/file.yaml, dir:///path/to/directory, mem://. Consul endpoint in format `address:port` is allowed too")
+	prefixRaw := flags.String("pref", "", "Prefix for all keys")
+	output := flags.String("o", textOutput, "Output format: text, json")
+	xmlAttrPrefix := flags.String("xml-attr-prefix", tree.DefaultXMLConfig.AttrPrefix, xmlAttrPrefixUsage)
+	yamlDocumentKey := flags.String("yaml-doc-key", "", yamlDocumentKeyUsage)
+	naming := flags.String("naming", "", namingUsage)
+	mergeBranches := flags.String("merge-branches", string(tree.BranchReplace), mergeBranchesUsage)
+	mergeKey := flags.String("merge-key", "", mergeKeyUsage)
+
+	check(flags.Parse(args))
+	if formatRaw == nil || storageURL == nil || prefixRaw == nil || output == nil || xmlAttrPrefix == nil ||
+		yamlDocumentKey == nil || naming == nil || mergeBranches == nil || mergeKey == nil {
+		panic("Impossible! Flags with defaults can't be nil")
+	}
+
+	mergeOpts := tree.MergeOptions{Branches: tree.BranchStrategy(*mergeBranches), KeyField: *mergeKey}
+	kv := readKV(paths.values, *formatRaw, *prefixRaw, *naming, mergeOpts,
+		cimp.WithXMLConfig(xmlConfig(*xmlAttrPrefix)), cimp.WithYAMLDocumentKey(*yamlDocumentKey))
+
+	storage, err := cimp.NewStorageFromURL(*storageURL)
 	check(err)
 
-	check(storage.Save(kv))
+	storedKV, err := storage.Load(*prefixRaw)
+	check(err)
+
+	changes, err := cimp.Diff(storedKV, kv)
+	check(err)
+
+	switch *output {
+	case textOutput:
+		for _, c := range changes {
+			switch c.Type {
+			case cimp.ChangeAdded:
+				fmt.Printf("+ %s = %q\n", c.Key, *c.NewValue)
+			case cimp.ChangeModified:
+				fmt.Printf("~ %s: %q -> %q\n", c.Key, *c.OldValue, *c.NewValue)
+			case cimp.ChangeRemoved:
+				fmt.Printf("- %s = %q\n", c.Key, *c.OldValue)
+			}
+		}
+	case jsonOutput:
+		if changes == nil {
+			changes = []cimp.Change{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		check(encoder.Encode(changes))
+	default:
+		panic(fmt.Sprintf("unknown output format %q", *output))
+	}
+}
+
+func printPlan(batches []api.TxnOps) {
+	var total int
+	for i, ops := range batches {
+		fmt.Printf("transaction #%d (%d ops):\n", i+1, len(ops))
+		for _, op := range ops {
+			if op.KV.Verb == api.KVDelete {
+				fmt.Printf("  %s %s\n", op.KV.Verb, op.KV.Key)
+				continue
+			}
+			fmt.Printf("  %s %s = %q\n", op.KV.Verb, op.KV.Key, op.KV.Value)
+		}
+		total += len(ops)
+	}
+	fmt.Printf("total: %d ops in %d transactions\n", total, len(batches))
+}
+
+// readKV reads config-files into KV with the global prefix. Later files are merged over earlier ones.
+func readKV(paths []string, formatRaw, prefix, namingRaw string, mergeOpts tree.MergeOptions, opts ...cimp.MarshalerOption) *cimp.KV {
+	var naming tree.KeyNaming
+	if namingRaw != "" {
+		var err error
+		naming, err = tree.KeyNamingByName(namingRaw)
+		check(err)
+	}
+
+	var kv *cimp.KV
+	for _, pathRaw := range paths {
+		fileKV := readFile(pathRaw, formatRaw, prefix, naming, opts...)
+		if kv == nil {
+			kv = fileKV
+			continue
+		}
+		check(kv.Merge(fileKV, mergeOpts))
+	}
+
+	return kv
+}
+
+// readFile reads one config-file into KV with the global prefix.
+// Non-empty prefix is set before reading, because files in consul format contain it in keys.
+func readFile(pathRaw, formatRaw, prefix string, naming tree.KeyNaming, opts ...cimp.MarshalerOption) *cimp.KV {
+	path, err := filepath.Abs(pathRaw)
+	check(err)
+
+	format, err := cimp.NewFormat(formatRaw, path)
+	check(err)
+
+	cfgRaw, err := ioutil.ReadFile(path)
+	check(err)
+
+	kv := cimp.NewKV(tree.New(), cimp.WithKeyNaming(naming))
+	if len(prefix) > 0 {
+		kv.AddPrefix(prefix)
+	}
+	unmarshaler := cimp.NewUnmarshaler(kv, format, opts...)
+	check(unmarshaler.Unmarshal(cfgRaw))
+	kv.AddPrefix(prefix)
+
+	return kv
+}
+
+func xmlConfig(attrPrefix string) tree.XMLConfig {
+	cfg := tree.DefaultXMLConfig
+	cfg.AttrPrefix = attrPrefix
+
+	return cfg
+}
+
+// pathsFlag collects values of the repeated flag, the default value is dropped when the flag is set.
+type pathsFlag struct {
+	values []string
+	isSet  bool
+}
+
+func newPathsFlag(defaultValue string) *pathsFlag {
+	return &pathsFlag{values: []string{defaultValue}}
+}
+
+func (f *pathsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return strings.Join(f.values, ", ")
+}
+
+func (f *pathsFlag) Set(value string) error {
+	if !f.isSet {
+		f.values, f.isSet = nil, true
+	}
+	f.values = append(f.values, value)
+
+	return nil
 }
 
 func check(err error) {
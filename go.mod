@@ -3,8 +3,15 @@ module github.com/humans-group/cimp
 go 1.15
 
 require (
-	github.com/google/btree v1.0.0 // indirect
+	github.com/google/btree v1.0.1 // indirect
 	github.com/hashicorp/consul/api v1.8.1
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
+	github.com/hashicorp/hcl/v2 v2.10.0
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pelletier/go-toml v1.9.5
+	github.com/zclconf/go-cty v1.8.0
+	go.etcd.io/etcd/client/v3 v3.5.0
+	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
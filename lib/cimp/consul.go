@@ -0,0 +1,260 @@
+package cimp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+type Config struct {
+	Address string
+	// NullPolicy defines how null leaves are saved, NullEmpty is used by default.
+	NullPolicy NullPolicy
+}
+
+// NullPolicy defines how null leaves are saved to consul.
+type NullPolicy string
+
+const (
+	// NullEmpty saves null leaves as empty values.
+	NullEmpty NullPolicy = "empty"
+	// NullSkip doesn't touch keys of null leaves.
+	NullSkip NullPolicy = "skip"
+	// NullDelete deletes keys of null leaves.
+	NullDelete NullPolicy = "delete"
+)
+
+type ConsulStorage struct {
+	client     *api.Client
+	nullPolicy NullPolicy
+}
+
+const consulTransactionLimit = 64
+
+func NewStorage(cfg Config) (*ConsulStorage, error) {
+	switch cfg.NullPolicy {
+	case "":
+		cfg.NullPolicy = NullEmpty
+	case NullEmpty, NullSkip, NullDelete:
+	default:
+		return nil, fmt.Errorf("unknown null policy %q", cfg.NullPolicy)
+	}
+
+	clientCfg := api.DefaultConfig()
+	clientCfg.Address = cfg.Address
+
+	client, err := api.NewClient(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create consul client: %w", err)
+	}
+
+	return &ConsulStorage{
+		client:     client,
+		nullPolicy: cfg.NullPolicy,
+	}, nil
+}
+
+func (cs *ConsulStorage) Save(kv *KV) error {
+	batches, err := cs.Plan(kv)
+	if err != nil {
+		return fmt.Errorf("plan consul transactions: %w", err)
+	}
+
+	return cs.execute(batches)
+}
+
+// Sync saves the KV and deletes keys under its global prefix which are absent in the KV.
+// If more than deletionLimit keys should be deleted, nothing is changed.
+// Sync isn't atomic if it needs more than one transaction: transactions executed before the failed one are kept,
+// but keys are deleted only after all of them are saved.
+func (cs *ConsulStorage) Sync(kv *KV, deletionLimit int) error {
+	batches, err := cs.PlanSync(kv, deletionLimit)
+	if err != nil {
+		return fmt.Errorf("plan consul transactions: %w", err)
+	}
+
+	return cs.execute(batches)
+}
+
+// Plan builds transaction batches which are executed by Save, without contacting consul.
+// Operations are sorted by key, every batch contains no more than consul allows for one transaction.
+func (cs *ConsulStorage) Plan(kv *KV) ([]api.TxnOps, error) {
+	ops, err := cs.setOps(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitToBatches(ops), nil
+}
+
+// PlanSync builds transaction batches which are executed by Sync.
+// It only reads keys from consul to find orphans. Batches of DELETE-operations of orphans follow batches of SET-operations
+// and never share a transaction with them.
+func (cs *ConsulStorage) PlanSync(kv *KV, deletionLimit int) ([]api.TxnOps, error) {
+	ops, err := cs.setOps(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	storedKeys, err := cs.List(kv.globalPrefix)
+	if err != nil {
+		return nil, err
+	}
+	orphans := kv.orphanKeys(storedKeys)
+
+	if len(orphans) > deletionLimit {
+		return nil, fmt.Errorf("%d keys should be deleted, limit is %d: %w", len(orphans), deletionLimit, ErrorDeletionLimitExceeded)
+	}
+
+	deleteOps := make(api.TxnOps, 0, len(orphans))
+	for _, key := range orphans {
+		deleteOps = append(deleteOps, &api.TxnOp{
+			KV: &api.KVTxnOp{
+				Verb: api.KVDelete,
+				Key:  key,
+			},
+		})
+	}
+
+	return append(splitToBatches(ops), splitToBatches(deleteOps)...), nil
+}
+
+func (cs *ConsulStorage) execute(batches []api.TxnOps) error {
+	for i, ops := range batches {
+		ok, resp, _, err := cs.client.Txn().Txn(ops, nil)
+		if err != nil {
+			return fmt.Errorf("execute consul transaction #%d: %w", i+1, err)
+		}
+		if !ok {
+			return fmt.Errorf("consul transaction #%d: %s: %w", i+1, txnErrorsMessage(ops, resp), ErrorTransactionRolledBack)
+		}
+	}
+
+	return nil
+}
+
+// txnErrorsMessage describes errors of the rolled back transaction with keys of the failed operations.
+func txnErrorsMessage(ops api.TxnOps, resp *api.TxnResponse) string {
+	if resp == nil || len(resp.Errors) == 0 {
+		return "no errors are returned"
+	}
+
+	messages := make([]string, 0, len(resp.Errors))
+	for _, txnErr := range resp.Errors {
+		key := ""
+		if txnErr.OpIndex >= 0 && txnErr.OpIndex < len(ops) && ops[txnErr.OpIndex].KV != nil {
+			key = ops[txnErr.OpIndex].KV.Key
+		}
+		messages = append(messages, fmt.Sprintf("op #%d (key %q): %s", txnErr.OpIndex, key, txnErr.What))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// setOps returns SET-operations for all keys of the KV sorted by key.
+// Null leaves are skipped, set to empty values or deleted according to the null policy.
+func (cs *ConsulStorage) setOps(kv *KV) (api.TxnOps, error) {
+	keys := make([]string, 0, len(kv.idx))
+	for key := range kv.idx {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ops := make(api.TxnOps, 0, len(keys))
+	for _, key := range keys {
+		leaf, err := kv.tree.Get(kv.idx[key])
+		if err != nil {
+			return nil, fmt.Errorf("get key %q value from tree: %w", key, err)
+		}
+
+		if leaf.IsNull() {
+			switch cs.nullPolicy {
+			case NullSkip:
+				continue
+			case NullDelete:
+				ops = append(ops, &api.TxnOp{
+					KV: &api.KVTxnOp{
+						Verb: api.KVDelete,
+						Key:  kv.globalPrefix + key,
+					},
+				})
+				continue
+			}
+		}
+
+		ops = append(ops, &api.TxnOp{
+			KV: &api.KVTxnOp{
+				Verb:  api.KVSet,
+				Key:   kv.globalPrefix + key,
+				Value: []byte(leafValue(leaf)),
+			},
+		})
+	}
+
+	return ops, nil
+}
+
+// splitToBatches splits operations to batches which can be executed by one transaction.
+func splitToBatches(ops api.TxnOps) []api.TxnOps {
+	var batches []api.TxnOps
+	for len(ops) > consulTransactionLimit {
+		batches = append(batches, ops[:consulTransactionLimit])
+		ops = ops[consulTransactionLimit:]
+	}
+	if len(ops) > 0 {
+		batches = append(batches, ops)
+	}
+
+	return batches
+}
+
+// Load reads all keys stored under the prefix and builds KV from them.
+func (cs *ConsulStorage) Load(prefix string) (*KV, error) {
+	pairs, _, err := cs.client.KV().List(prefix, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list consul keys by prefix %q: %w", prefix, err)
+	}
+
+	flat := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		// folders have no values, their content is stored by nested keys
+		if strings.HasSuffix(pair.Key, consulSep) {
+			continue
+		}
+		flat[pair.Key] = string(pair.Value)
+	}
+
+	return loadFromFlat(flat, prefix)
+}
+
+func (cs *ConsulStorage) Delete(kv *KV) error {
+	for k := range kv.idx {
+		_, err := cs.client.KV().Delete(kv.globalPrefix+k, nil)
+		if err != nil {
+			return fmt.Errorf("delete %q from consul: %w", kv.globalPrefix+k, err)
+		}
+	}
+
+	return nil
+}
+
+// List returns sorted full keys stored under the prefix, folders are skipped.
+func (cs *ConsulStorage) List(prefix string) ([]string, error) {
+	storedKeys, _, err := cs.client.KV().Keys(prefix, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list consul keys by prefix %q: %w", prefix, err)
+	}
+
+	keys := make([]string, 0, len(storedKeys))
+	for _, key := range storedKeys {
+		if strings.HasSuffix(key, consulSep) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
@@ -0,0 +1,228 @@
+package cimp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/humans-group/cimp/lib/tree"
+)
+
+func TestConsulStorage_Plan(t *testing.T) {
+	var raw strings.Builder
+	for i := 0; i < consulTransactionLimit+1; i++ {
+		raw.WriteString(fmt.Sprintf("key%03d: %d\n", i, i))
+	}
+	kv := newTestKV(t, YAMLFormat, raw.String())
+
+	batches, err := (&ConsulStorage{}).Plan(kv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(batches) != 2 || len(batches[0]) != consulTransactionLimit || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches count %d", len(batches))
+	}
+	if key := batches[0][0].KV.Key; key != "service/key000" {
+		t.Errorf("first key %q != expectation %q", key, "service/key000")
+	}
+	if key := batches[1][0].KV.Key; key != fmt.Sprintf("service/key%03d", consulTransactionLimit) {
+		t.Errorf("last key %q is unexpected", key)
+	}
+}
+
+func TestConsulStorage_PlanNulls(t *testing.T) {
+	kv := newTestKV(t, JSONFormat, `{"name": "cimp", "empty": null}`)
+
+	tests := []struct {
+		policy NullPolicy
+		expOps []string
+	}{
+		{policy: NullEmpty, expOps: []string{`set service/empty = ""`, `set service/name = "cimp"`}},
+		{policy: NullSkip, expOps: []string{`set service/name = "cimp"`}},
+		{policy: NullDelete, expOps: []string{`delete service/empty = ""`, `set service/name = "cimp"`}},
+	}
+
+	for _, tc := range tests {
+		batches, err := (&ConsulStorage{nullPolicy: tc.policy}).Plan(kv)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.policy, err)
+		}
+
+		var ops []string
+		for _, op := range batches[0] {
+			ops = append(ops, fmt.Sprintf("%s %s = %q", op.KV.Verb, op.KV.Key, op.KV.Value))
+		}
+		if !reflect.DeepEqual(ops, tc.expOps) {
+			t.Errorf("%s: operations %q != expectation %q", tc.policy, ops, tc.expOps)
+		}
+	}
+}
+
+func TestKV_OrphanKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		storedKeys []string
+		expOrphans []string
+	}{
+		{
+			name:       "absent keys",
+			prefix:     "service",
+			storedKeys: []string{"service/name", "service/old", "service/hosts/0", "service/hosts/5"},
+			expOrphans: []string{"service/old", "service/hosts/5"},
+		},
+		{
+			name:       "keys out of prefix",
+			prefix:     "service",
+			storedKeys: []string{"service", "service/", "services/old", "other/name", "service/name"},
+		},
+		{
+			name:       "folder keys",
+			prefix:     "service",
+			storedKeys: []string{"service/hosts/", "service/old/", "service/old/key"},
+			expOrphans: []string{"service/old/key"},
+		},
+		{
+			name:       "escaped keys",
+			prefix:     "service",
+			storedKeys: []string{"service/a%2Fb", "service/a/b", "service/100%25"},
+			expOrphans: []string{"service/a/b", "service/100%25"},
+		},
+		{
+			name:       "without prefix",
+			prefix:     "",
+			storedKeys: []string{"name", "other/name", "hosts/1"},
+			expOrphans: []string{"other/name"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			kv := NewKV(tree.New(), WithKeyNaming(tree.VerbatimNaming))
+			if err := NewUnmarshaler(kv, YAMLFormat).Unmarshal([]byte("name: cimp\nhosts: [a, b]\n\"a/b\": 1\n")); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if tc.prefix != "" {
+				kv.AddPrefix(tc.prefix)
+			}
+
+			if orphans := kv.orphanKeys(tc.storedKeys); !reflect.DeepEqual(orphans, tc.expOrphans) {
+				t.Errorf("orphans %q != expectation %q", orphans, tc.expOrphans)
+			}
+		})
+	}
+}
+
+// newTestConsul returns storage connected to fake consul which lists the stored keys.
+func newTestConsul(t *testing.T, storedKeys []string) *ConsulStorage {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		keys := []string{}
+		for _, key := range storedKeys {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(server.Close)
+
+	storage, err := NewStorage(Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+
+	return storage
+}
+
+func TestConsulStorage_PlanSync(t *testing.T) {
+	var raw strings.Builder
+	for i := 0; i < consulTransactionLimit; i++ {
+		raw.WriteString(fmt.Sprintf("key%03d: %d\n", i, i))
+	}
+	kv := newTestKV(t, YAMLFormat, raw.String())
+
+	storedKeys := []string{"service/", "service/key000", "service/old1", "service/old2/", "service/old2/a", "other/key"}
+	storage := newTestConsul(t, storedKeys)
+
+	batches, err := storage.PlanSync(kv, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != consulTransactionLimit || len(batches[1]) != 2 {
+		t.Fatalf("unexpected batches %d", len(batches))
+	}
+	for _, op := range batches[0] {
+		if op.KV.Verb != api.KVSet {
+			t.Errorf("unexpected %s of %q in the first batch", op.KV.Verb, op.KV.Key)
+		}
+	}
+	var deleted []string
+	for _, op := range batches[1] {
+		if op.KV.Verb != api.KVDelete {
+			t.Errorf("unexpected %s of %q after SET-operations", op.KV.Verb, op.KV.Key)
+		}
+		deleted = append(deleted, op.KV.Key)
+	}
+	if expDeleted := []string{"service/old1", "service/old2/a"}; !reflect.DeepEqual(deleted, expDeleted) {
+		t.Errorf("deleted keys %q != expectation %q", deleted, expDeleted)
+	}
+
+	if _, err := storage.PlanSync(kv, 1); !errors.Is(err, ErrorDeletionLimitExceeded) {
+		t.Errorf("expected deletion limit error, got %v", err)
+	}
+
+	// deletions don't share a transaction with SET-operations even if they fit into it
+	smallKV := newTestKV(t, YAMLFormat, "key000: 0\n")
+	batches, err = storage.PlanSync(smallKV, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 1 || len(batches[1]) != 2 {
+		t.Fatalf("unexpected batches %d", len(batches))
+	}
+	if batches[0][0].KV.Verb != api.KVSet || batches[1][0].KV.Verb != api.KVDelete {
+		t.Errorf("unexpected operations %s, %s", batches[0][0].KV.Verb, batches[1][0].KV.Verb)
+	}
+}
+
+func TestConsulStorage_SaveRolledBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1/txn" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(api.TxnResponse{
+			Errors: api.TxnErrors{{OpIndex: 1, What: "permission denied"}},
+		})
+	}))
+	defer server.Close()
+
+	storage, err := NewStorage(Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+
+	err = storage.Save(newTestKV(t, YAMLFormat, "a: 1\nb: 2\n"))
+	if !errors.Is(err, ErrorTransactionRolledBack) {
+		t.Fatalf("expected rolled back transaction error, got %v", err)
+	}
+	if exp := `op #1 (key "service/b"): permission denied`; !strings.Contains(err.Error(), exp) {
+		t.Errorf("error %q doesn't contain %q", err.Error(), exp)
+	}
+}
@@ -0,0 +1,72 @@
+package cimp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/humans-group/cimp/lib/tree"
+)
+
+// consulExportEntry is an element of JSON array which is produced by `consul kv export` and consumed by `consul kv import`.
+type consulExportEntry struct {
+	Key   string `json:"key"`
+	Flags uint64 `json:"flags"`
+	Value string `json:"value"`
+}
+
+// marshalConsulExport writes leaves of the KV with the global prefix as consul export entries sorted by keys.
+func marshalConsulExport(kv *KV, indent int) ([]byte, error) {
+	flat, err := kv.flatten()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]consulExportEntry, 0, len(flat))
+	for _, key := range sortedKeys(flat) {
+		entries = append(entries, consulExportEntry{
+			Key:   key,
+			Value: base64.StdEncoding.EncodeToString([]byte(flat[key])),
+		})
+	}
+
+	raw, err := json.MarshalIndent(entries, "", strings.Repeat(" ", indent))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(raw, '\n'), nil
+}
+
+// unmarshalConsulExport fills KV from consul export entries. Keys must be under the global prefix,
+// which is stripped as marshalConsulExport adds it. Folder keys are skipped.
+func unmarshalConsulExport(kv *KV, raw []byte) error {
+	var entries []consulExportEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return err
+	}
+
+	flat := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Key, consulSep) {
+			continue
+		}
+		if !strings.HasPrefix(entry.Key, kv.globalPrefix) {
+			return fmt.Errorf("key %q is out of prefix %q", entry.Key, kv.globalPrefix)
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			return fmt.Errorf("decode value of %q: %w", entry.Key, err)
+		}
+		flat[entry.Key[len(kv.globalPrefix):]] = string(value)
+	}
+
+	t, err := tree.NewFromFlat(flat, tree.WithKeyNaming(tree.VerbatimNaming))
+	if err != nil {
+		return err
+	}
+	kv.SetTree(t)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cimp
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+	ChangeRemoved  ChangeType = "removed"
+)
+
+// Change describes difference of one leaf between two KVs.
+// OldValue is nil for added keys and NewValue is nil for removed ones, so empty values are kept.
+type Change struct {
+	Type     ChangeType `json:"type"`
+	Key      string     `json:"key"`
+	OldValue *string    `json:"old_value"`
+	NewValue *string    `json:"new_value"`
+}
+
+// Diff compares leaves of two KVs and returns changes which turn oldKV into newKV, sorted by key.
+// Keys are reported with the global prefix of newKV. Values are compared as they would be stored.
+func Diff(oldKV, newKV *KV) ([]Change, error) {
+	var changes []Change
+
+	for key, path := range newKV.idx {
+		newLeaf, err := newKV.tree.Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("get key %q value from new tree: %w", key, err)
+		}
+		newValue := leafValue(newLeaf)
+		change := Change{
+			Key:      newKV.globalPrefix + key,
+			NewValue: &newValue,
+		}
+
+		oldPath, ok := oldKV.idx[key]
+		if !ok {
+			change.Type = ChangeAdded
+			changes = append(changes, change)
+			continue
+		}
+
+		oldLeaf, err := oldKV.tree.Get(oldPath)
+		if err != nil {
+			return nil, fmt.Errorf("get key %q value from old tree: %w", key, err)
+		}
+		oldValue := leafValue(oldLeaf)
+		change.OldValue = &oldValue
+		if oldValue != newValue {
+			change.Type = ChangeModified
+			changes = append(changes, change)
+		}
+	}
+
+	for key, path := range oldKV.idx {
+		if _, ok := newKV.idx[key]; ok {
+			continue
+		}
+		oldLeaf, err := oldKV.tree.Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("get key %q value from old tree: %w", key, err)
+		}
+		oldValue := leafValue(oldLeaf)
+		changes = append(changes, Change{
+			Type:     ChangeRemoved,
+			Key:      newKV.globalPrefix + key,
+			OldValue: &oldValue,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes, nil
+}
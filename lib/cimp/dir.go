@@ -0,0 +1,135 @@
+package cimp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirStorage keeps every key as a file in the directory tree: the full key is a path of the file
+// relative to the root directory and the value is its content. Branch elements are directories named by indexes.
+type DirStorage struct {
+	root string
+}
+
+func NewDirStorage(root string) (*DirStorage, error) {
+	root = filepath.Clean(root)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create root directory %q: %w", root, err)
+	}
+
+	return &DirStorage{
+		root: root,
+	}, nil
+}
+
+func (ds *DirStorage) Save(kv *KV) error {
+	flat, err := kv.flatten()
+	if err != nil {
+		return fmt.Errorf("flatten KV: %w", err)
+	}
+
+	for _, key := range sortedKeys(flat) {
+		path, err := ds.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("create directory for key %q: %w", key, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(flat[key]), 0644); err != nil {
+			return fmt.Errorf("write key %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (ds *DirStorage) Load(prefix string) (*KV, error) {
+	keys, err := ds.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]string, len(keys))
+	for _, key := range keys {
+		path, err := ds.path(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read key %q: %w", key, err)
+		}
+		flat[key] = string(value)
+	}
+
+	return loadFromFlat(flat, prefix)
+}
+
+// Delete removes files of all keys of the KV and directories which become empty after that.
+func (ds *DirStorage) Delete(kv *KV) error {
+	for key := range kv.idx {
+		path, err := ds.path(kv.globalPrefix + key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("delete key %q: %w", kv.globalPrefix+key, err)
+		}
+
+		for dir := filepath.Dir(path); dir != ds.root && strings.HasPrefix(dir, ds.root); dir = filepath.Dir(dir) {
+			// only empty directories can be removed, so the first error means that the rest of path is used
+			if err := os.Remove(dir); err != nil {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ds *DirStorage) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(ds.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(ds.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relPath)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk directory %q: %w", ds.root, err)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// path returns path of the file for the key. Every part of the key must be a valid file name.
+func (ds *DirStorage) path(key string) (string, error) {
+	parts := strings.Split(key, consulSep)
+	for _, part := range parts {
+		if len(part) == 0 || part == "." || part == ".." {
+			return "", fmt.Errorf("key %q can't be stored as a file path", key)
+		}
+	}
+
+	return filepath.Join(append([]string{ds.root}, parts...)...), nil
+}
@@ -3,7 +3,9 @@ package cimp
 import "fmt"
 
 var (
-	ErrorNotFoundInKV       = fmt.Errorf("value is not found in KV")
-	ErrorParentNotFoundInKV = fmt.Errorf("parent value is not found in KV")
-	ErrorTypeIncorrect      = fmt.Errorf("type is incorrect")
+	ErrorNotFoundInKV          = fmt.Errorf("value is not found in KV")
+	ErrorParentNotFoundInKV    = fmt.Errorf("parent value is not found in KV")
+	ErrorTypeIncorrect         = fmt.Errorf("type is incorrect")
+	ErrorDeletionLimitExceeded = fmt.Errorf("deletion limit is exceeded")
+	ErrorTransactionRolledBack = fmt.Errorf("transaction is rolled back")
 )
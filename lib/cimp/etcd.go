@@ -0,0 +1,135 @@
+package cimp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type EtcdConfig struct {
+	Endpoints      []string
+	DialTimeout    time.Duration
+	RequestTimeout time.Duration
+}
+
+type EtcdStorage struct {
+	client         *clientv3.Client
+	requestTimeout time.Duration
+}
+
+const (
+	// etcdTransactionLimit is the default value of `--max-txn-ops` of etcd server
+	etcdTransactionLimit = 128
+
+	defaultEtcdDialTimeout    = 5 * time.Second
+	defaultEtcdRequestTimeout = 10 * time.Second
+)
+
+func NewEtcdStorage(cfg EtcdConfig) (*EtcdStorage, error) {
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultEtcdDialTimeout
+	}
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = defaultEtcdRequestTimeout
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: cfg.DialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create etcd client: %w", err)
+	}
+
+	return &EtcdStorage{
+		client:         client,
+		requestTimeout: cfg.RequestTimeout,
+	}, nil
+}
+
+func (es *EtcdStorage) Save(kv *KV) error {
+	flat, err := kv.flatten()
+	if err != nil {
+		return fmt.Errorf("flatten KV: %w", err)
+	}
+
+	ops := make([]clientv3.Op, 0, len(flat))
+	for _, key := range sortedKeys(flat) {
+		ops = append(ops, clientv3.OpPut(key, flat[key]))
+	}
+
+	return es.execute(ops)
+}
+
+func (es *EtcdStorage) Load(prefix string) (*KV, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), es.requestTimeout)
+	defer cancel()
+
+	resp, err := es.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("get etcd keys by prefix %q: %w", prefix, err)
+	}
+
+	flat := make(map[string]string, len(resp.Kvs))
+	for _, pair := range resp.Kvs {
+		flat[string(pair.Key)] = string(pair.Value)
+	}
+
+	return loadFromFlat(flat, prefix)
+}
+
+func (es *EtcdStorage) Delete(kv *KV) error {
+	ops := make([]clientv3.Op, 0, len(kv.idx))
+	for key := range kv.idx {
+		ops = append(ops, clientv3.OpDelete(kv.globalPrefix+key))
+	}
+
+	return es.execute(ops)
+}
+
+func (es *EtcdStorage) List(prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), es.requestTimeout)
+	defer cancel()
+
+	resp, err := es.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("list etcd keys by prefix %q: %w", prefix, err)
+	}
+
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, pair := range resp.Kvs {
+		keys = append(keys, string(pair.Key))
+	}
+
+	return keys, nil
+}
+
+func (es *EtcdStorage) Close() error {
+	return es.client.Close()
+}
+
+// execute commits operations by transactions, every transaction contains no more than etcd allows.
+func (es *EtcdStorage) execute(ops []clientv3.Op) error {
+	for len(ops) > 0 {
+		batchSize := len(ops)
+		if batchSize > etcdTransactionLimit {
+			batchSize = etcdTransactionLimit
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), es.requestTimeout)
+		resp, err := es.client.Txn(ctx).Then(ops[:batchSize]...).Commit()
+		cancel()
+		if err != nil {
+			return fmt.Errorf("execute etcd transaction: %w", err)
+		}
+		if !resp.Succeeded {
+			return fmt.Errorf("etcd transaction is not succeeded")
+		}
+
+		ops = ops[batchSize:]
+	}
+
+	return nil
+}
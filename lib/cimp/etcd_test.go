@@ -0,0 +1,78 @@
+package cimp
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// etcdEndpointEnv names the variable with the endpoint of etcd which the storage is tested against,
+// tests are skipped without it.
+const etcdEndpointEnv = "CIMP_TEST_ETCD_ENDPOINT"
+
+func testEtcdEndpoint(t *testing.T) string {
+	t.Helper()
+
+	endpoint := os.Getenv(etcdEndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", etcdEndpointEnv)
+	}
+
+	return endpoint
+}
+
+func TestEtcdStorage(t *testing.T) {
+	endpoint := testEtcdEndpoint(t)
+
+	storage, err := NewStorageFromURL("etcd://" + endpoint)
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer storage.(*EtcdStorage).Close()
+
+	// more keys than one transaction can contain
+	var raw strings.Builder
+	for i := 0; i < etcdTransactionLimit+1; i++ {
+		raw.WriteString(fmt.Sprintf("key%03d: %d\n", i, i))
+	}
+	raw.WriteString("hosts: [a, b]\n")
+	kv := newTestKV(t, YAMLFormat, raw.String())
+
+	if err := storage.Save(kv); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	keys, err := storage.List("service/hosts")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	expKeys := []string{"service/hosts/0", "service/hosts/1"}
+	if !reflect.DeepEqual(keys, expKeys) {
+		t.Errorf("keys %v != expectation %v", keys, expKeys)
+	}
+
+	loadedKV, err := storage.Load("service")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	changes, err := Diff(loadedKV, kv)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(changes) > 0 {
+		t.Errorf("loaded KV differs from saved one: %+v", changes)
+	}
+
+	if err := storage.Delete(kv); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	keys, err = storage.List("service/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(keys) > 0 {
+		t.Errorf("keys %v are left after deletion", keys)
+	}
+}
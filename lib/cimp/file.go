@@ -0,0 +1,117 @@
+package cimp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/humans-group/cimp/lib/tree"
+)
+
+// FileStorage keeps all keys in one config-file, its format is got from the extension.
+// Every operation reads the file and writes it back if something is changed.
+type FileStorage struct {
+	path         string
+	format       FileFormat
+	indentSpaces int
+}
+
+const fileStorageIndent = 2
+
+func NewFileStorage(path string) (*FileStorage, error) {
+	format, err := NewFormat("", path)
+	if err != nil {
+		return nil, fmt.Errorf("detect format of file %q: %w", path, err)
+	}
+
+	return &FileStorage{
+		path:         path,
+		format:       format,
+		indentSpaces: fileStorageIndent,
+	}, nil
+}
+
+func (fs *FileStorage) Save(kv *KV) error {
+	ms, err := fs.read()
+	if err != nil {
+		return err
+	}
+	if err := ms.Save(kv); err != nil {
+		return err
+	}
+
+	return fs.write(ms)
+}
+
+func (fs *FileStorage) Load(prefix string) (*KV, error) {
+	ms, err := fs.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return ms.Load(prefix)
+}
+
+func (fs *FileStorage) Delete(kv *KV) error {
+	ms, err := fs.read()
+	if err != nil {
+		return err
+	}
+	if err := ms.Delete(kv); err != nil {
+		return err
+	}
+
+	return fs.write(ms)
+}
+
+func (fs *FileStorage) List(prefix string) ([]string, error) {
+	ms, err := fs.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return ms.List(prefix)
+}
+
+// read loads all keys of the file to memory. Absent file is treated as empty.
+func (fs *FileStorage) read() (*MemStorage, error) {
+	ms := NewMemStorage()
+
+	raw, err := ioutil.ReadFile(fs.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ms, nil
+		}
+		return nil, fmt.Errorf("read file %q: %w", fs.path, err)
+	}
+
+	// keys of the file are already final, so they are kept as is
+	kv := NewKV(tree.New(), WithKeyNaming(tree.VerbatimNaming))
+	if err := NewUnmarshaler(kv, fs.format).Unmarshal(raw); err != nil {
+		return nil, fmt.Errorf("unmarshal file %q: %w", fs.path, err)
+	}
+	if err := ms.Save(kv); err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
+func (fs *FileStorage) write(ms *MemStorage) error {
+	kv, err := ms.Load("")
+	if err != nil {
+		return err
+	}
+
+	raw, err := NewMarshaler(kv, fs.format, fs.indentSpaces).Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal file %q: %w", fs.path, err)
+	}
+
+	if err := ioutil.WriteFile(fs.path, raw, 0644); err != nil {
+		return fmt.Errorf("write file %q: %w", fs.path, err)
+	}
+
+	return nil
+}
@@ -10,6 +10,19 @@ type FileFormat string
 const (
 	JSONFormat FileFormat = "json"
 	YAMLFormat FileFormat = "yaml"
+	EDNFormat  FileFormat = "edn"
+	TOMLFormat FileFormat = "toml"
+	HCLFormat  FileFormat = "hcl"
+
+	PropertiesFormat FileFormat = "properties"
+	DotenvFormat     FileFormat = "env"
+	INIFormat        FileFormat = "ini"
+	XMLFormat        FileFormat = "xml"
+	// JSON5Format is read by lenient JSON5 parser, which covers JSON with comments, and written as plain JSON.
+	JSON5Format FileFormat = "json5"
+
+	// ConsulExportFormat is JSON array of keys with base64 encoded values as in `consul kv export`.
+	ConsulExportFormat FileFormat = "consul"
 )
 
 func NewFormat(format, path string) (FileFormat, error) {
@@ -19,6 +32,24 @@ func NewFormat(format, path string) (FileFormat, error) {
 			return JSONFormat, nil
 		case YAMLFormat:
 			return YAMLFormat, nil
+		case EDNFormat:
+			return EDNFormat, nil
+		case TOMLFormat:
+			return TOMLFormat, nil
+		case HCLFormat:
+			return HCLFormat, nil
+		case PropertiesFormat:
+			return PropertiesFormat, nil
+		case DotenvFormat:
+			return DotenvFormat, nil
+		case INIFormat:
+			return INIFormat, nil
+		case XMLFormat:
+			return XMLFormat, nil
+		case JSON5Format:
+			return JSON5Format, nil
+		case ConsulExportFormat:
+			return ConsulExportFormat, nil
 		default:
 			return "", fmt.Errorf("undefined format: %s", format)
 		}
@@ -29,6 +60,22 @@ func NewFormat(format, path string) (FileFormat, error) {
 		return JSONFormat, nil
 	case ".yml", ".yaml":
 		return YAMLFormat, nil
+	case ".edn":
+		return EDNFormat, nil
+	case ".toml":
+		return TOMLFormat, nil
+	case ".hcl", ".tf":
+		return HCLFormat, nil
+	case ".properties":
+		return PropertiesFormat, nil
+	case ".env":
+		return DotenvFormat, nil
+	case ".ini":
+		return INIFormat, nil
+	case ".xml":
+		return XMLFormat, nil
+	case ".json5", ".jsonc":
+		return JSON5Format, nil
 	}
 
 	return YAMLFormat, nil
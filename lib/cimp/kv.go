@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -17,8 +18,12 @@ type KV struct {
 	tree         *tree.Tree
 	idx          index
 	globalPrefix string
+	naming       tree.KeyNaming
 }
 
+// KVOption configures KV created by NewKV.
+type KVOption func(kv *KV)
+
 type index map[string]tree.Path
 
 type TreeConverter interface {
@@ -38,13 +43,22 @@ type branchesToTreeConverter struct {
 
 const consulSep = "/"
 
-func NewKV(t *tree.Tree) *KV {
-	idx := index(make(map[string]tree.Path))
-	idx.addKeys(t, nil)
+func NewKV(t *tree.Tree, opts ...KVOption) *KV {
+	kv := &KV{
+		idx: index(make(map[string]tree.Path)),
+	}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	kv.SetTree(t)
+
+	return kv
+}
 
-	return &KV{
-		tree: t,
-		idx:  idx,
+// WithKeyNaming sets policy of converting names to full keys for the tree of KV and all trees set later.
+func WithKeyNaming(naming tree.KeyNaming) KVOption {
+	return func(kv *KV) {
+		kv.naming = naming
 	}
 }
 
@@ -59,7 +73,7 @@ func (kv *KV) SetIfExist(key string, value interface{}) error {
 		return fmt.Errorf("get by path: %w", err)
 	}
 
-	leaf.Value = value
+	leaf.SetValue(value)
 
 	return nil
 }
@@ -152,6 +166,9 @@ func (kv *KV) AddPrefix(prefix string) {
 }
 
 func (kv *KV) SetTree(t *tree.Tree) {
+	if kv.naming != nil && t != nil {
+		t.SetKeyNaming(kv.naming)
+	}
 	kv.tree = t
 	kv.idx.clear()
 	kv.idx.addKeys(t, nil)
@@ -163,12 +180,23 @@ func (kv *KV) Walk(walkFunc tree.WalkFunc) {
 
 func (kv *KV) DeepClone() *KV {
 	newTree := kv.tree.DeepClone()
-	newKV := NewKV(newTree)
+	newKV := NewKV(newTree, WithKeyNaming(kv.naming))
 	newKV.globalPrefix = kv.globalPrefix
 
 	return newKV
 }
 
+// Merge deeply merges the tree of the overlay KV into the tree of KV, see tree.Merge for details.
+func (kv *KV) Merge(overlay *KV, opts tree.MergeOptions) error {
+	merged, err := tree.Merge(kv.tree, overlay.tree, opts)
+	if err != nil {
+		return fmt.Errorf("merge trees: %w", err)
+	}
+	kv.SetTree(merged)
+
+	return nil
+}
+
 func (kv *KV) ConvertBranchesToString(format FileFormat, indent int, exceptions map[string]string) error {
 	tc := branchesToStringConverter{
 		Format:     format,
@@ -217,32 +245,45 @@ func (kv *KV) ConvertBranchesKeysAsForTree(branchPathToBranchElementFieldName ma
 	return nil
 }
 
-func (kv *KV) ConvertTreeNamesToCamelCase() {
-	kv.setNamesToSnakeCase(kv.tree)
+// ConvertTreeNamesToCamelCase converts names of all nodes by the key naming of KV, it's snake case by default,
+// so names become the same as parts of their full keys.
+// If sibling names are converted to the same name, *tree.CollisionError is returned and the tree isn't changed.
+func (kv *KV) ConvertTreeNamesToCamelCase() error {
+	naming := kv.naming
+	if naming == nil {
+		naming = tree.SnakeCaseNaming
+	}
+	if err := kv.tree.CheckKeyCollisions(naming); err != nil {
+		return fmt.Errorf("convert tree names: %w", err)
+	}
+
+	setNames(kv.tree, naming)
+	kv.tree.SetKeyNaming(naming)
 	kv.idx.clear()
 	kv.idx.addKeys(kv.tree, nil)
+
+	return nil
 }
 
-func (kv *KV) setNamesToSnakeCase(m tree.Marshalable) {
+func setNames(m tree.Marshalable, naming tree.KeyNaming) {
 	switch item := m.(type) {
 	case *tree.Leaf:
-		item.Name = tree.ToSnakeCase(item.Name)
+		item.Name = item.ConvertName(naming)
 	case *tree.Branch:
 		for i := range item.Content {
-			kv.setNamesToSnakeCase(item.Content[i])
+			setNames(item.Content[i], naming)
 		}
-		item.Name = tree.ToSnakeCase(item.Name)
+		item.Name = naming.ConvertName(item.Name)
 	case *tree.Tree:
-		item.Name = tree.ToSnakeCase(item.Name)
-		for k, v := range item.Content {
-			kv.setNamesToSnakeCase(v)
-			delete(item.Content, k)
-			k = tree.ToSnakeCase(k)
-			item.Content[k] = v
-		}
+		item.Name = naming.ConvertName(item.Name)
+		content := make(map[string]tree.Marshalable, len(item.Content))
 		for i, name := range item.Order {
-			item.Order[i] = tree.ToSnakeCase(name)
+			child := item.Content[name]
+			setNames(child, naming)
+			item.Order[i] = child.GetName()
+			content[child.GetName()] = child
 		}
+		item.Content = content
 	}
 }
 
@@ -336,11 +377,10 @@ func (c branchesToTreeConverter) Convert(mt *tree.Tree) (*tree.Tree, error) {
 					if !isTreeElementLeaf {
 						return nil, fmt.Errorf("field %q of element #%d of branch %q is not a leaf", fieldName, elementIdx+1, v.GetFullKey())
 					}
-					treeElementValueAsString, isTreeElementValueString := treeElementAsLeaf.Value.(string)
-					if !isTreeElementValueString {
-						return nil, fmt.Errorf("field %q of element #%d of branch %q is not a string", fieldName, elementIdx+1, v.GetFullKey())
+					if treeElementAsLeaf.Type() == tree.NullType {
+						return nil, fmt.Errorf("field %q of element #%d of branch %q is null", fieldName, elementIdx+1, v.GetFullKey())
 					}
-					branchElementName = treeElementValueAsString
+					branchElementName = tree.FormatScalar(treeElementAsLeaf.Value)
 					break
 				}
 				if !isBranchElementHaveField {
@@ -370,6 +410,55 @@ func (c branchesToTreeConverter) Convert(mt *tree.Tree) (*tree.Tree, error) {
 	return newTree, nil
 }
 
+// flatten returns values of all leaves by full keys with the global prefix.
+func (kv *KV) flatten() (map[string]string, error) {
+	flat := make(map[string]string, len(kv.idx))
+	for key, path := range kv.idx {
+		leaf, err := kv.tree.Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("get key %q value from tree: %w", key, err)
+		}
+		flat[kv.globalPrefix+key] = leafValue(leaf)
+	}
+
+	return flat, nil
+}
+
+func sortedKeys(flat map[string]string) []string {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// orphanKeys returns stored full keys under the global prefix which are absent in the KV. Folder keys are skipped.
+func (kv *KV) orphanKeys(storedKeys []string) []string {
+	var orphans []string
+	for _, storedKey := range storedKeys {
+		if !strings.HasPrefix(storedKey, kv.globalPrefix) || len(storedKey) == len(kv.globalPrefix) ||
+			strings.HasSuffix(storedKey, consulSep) {
+			continue
+		}
+		if _, ok := kv.idx[storedKey[len(kv.globalPrefix):]]; !ok {
+			orphans = append(orphans, storedKey)
+		}
+	}
+
+	return orphans
+}
+
+// leafValue returns the value as it's stored in KV storages, null and empty leaves are stored as empty value.
+func leafValue(leaf *tree.Leaf) string {
+	if leaf.Value == nil {
+		return ""
+	}
+
+	return tree.FormatScalar(leaf.Value)
+}
+
 func (idx index) clear() {
 	for k := range idx {
 		delete(idx, k)
@@ -0,0 +1,247 @@
+package cimp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humans-group/cimp/lib/tree"
+)
+
+func newTestKV(t *testing.T, format FileFormat, raw string) *KV {
+	t.Helper()
+
+	kv := NewKV(tree.New())
+	if err := NewUnmarshaler(kv, format).Unmarshal([]byte(raw)); err != nil {
+		t.Fatalf("unmarshal test KV: %v", err)
+	}
+	kv.AddPrefix("service")
+
+	return kv
+}
+
+func TestDiff(t *testing.T) {
+	oldKV := newTestKV(t, YAMLFormat, `
+name: cimp
+port: 80
+hosts: [a, b]
+token: ""
+`)
+	newKV := newTestKV(t, YAMLFormat, `
+name: cimp
+port: 8080
+hosts: [a]
+debug: true
+secret: ""
+`)
+
+	changes, err := Diff(oldKV, newKV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	str := func(s string) *string { return &s }
+	expChanges := []Change{
+		{Type: ChangeAdded, Key: "service/debug", NewValue: str("true")},
+		{Type: ChangeRemoved, Key: "service/hosts/1", OldValue: str("b")},
+		{Type: ChangeModified, Key: "service/port", OldValue: str("80"), NewValue: str("8080")},
+		{Type: ChangeAdded, Key: "service/secret", NewValue: str("")},
+		{Type: ChangeRemoved, Key: "service/token", OldValue: str("")},
+	}
+	if !reflect.DeepEqual(changes, expChanges) {
+		t.Errorf("result %+v != expectation %+v", changes, expChanges)
+	}
+
+	raw, err := json.Marshal(changes[3:])
+	if err != nil {
+		t.Fatalf("marshal changes: %v", err)
+	}
+	exp := `[{"type":"added","key":"service/secret","old_value":null,"new_value":""},` +
+		`{"type":"removed","key":"service/token","old_value":"","new_value":null}]`
+	if string(raw) != exp {
+		t.Errorf("JSON result %s != expectation %s", raw, exp)
+	}
+}
+
+func TestConsulExportFormat(t *testing.T) {
+	kv := newTestKV(t, YAMLFormat, `
+name: cimp
+hosts: [a, b]
+`)
+
+	raw, err := NewMarshaler(kv, ConsulExportFormat, 2).Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	expRaw := `[
+  {
+    "key": "service/hosts/0",
+    "flags": 0,
+    "value": "YQ=="
+  },
+  {
+    "key": "service/hosts/1",
+    "flags": 0,
+    "value": "Yg=="
+  },
+  {
+    "key": "service/name",
+    "flags": 0,
+    "value": "Y2ltcA=="
+  }
+]
+`
+	if string(raw) != expRaw {
+		t.Errorf("result %q != expectation %q", string(raw), expRaw)
+	}
+
+	loaded := NewKV(tree.New())
+	if err := NewUnmarshaler(loaded, ConsulExportFormat).Unmarshal(raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	hosts, err := loaded.tree.GetByFullKey("service/hosts")
+	if err != nil {
+		t.Fatalf("get hosts: %v", err)
+	}
+	if _, ok := hosts.(*tree.Branch); !ok {
+		t.Errorf("hosts are unmarshaled as %T", hosts)
+	}
+	if v, err := loaded.GetString("service/name"); err != nil || v != "cimp" {
+		t.Errorf("unexpected name %q: %v", v, err)
+	}
+
+	// the prefix is stripped on reading and added back on writing
+	prefixed := NewKV(tree.New())
+	prefixed.AddPrefix("service")
+	if err := NewUnmarshaler(prefixed, ConsulExportFormat).Unmarshal(raw); err != nil {
+		t.Fatalf("unmarshal with prefix: %v", err)
+	}
+	if v, err := prefixed.GetString("name"); err != nil || v != "cimp" {
+		t.Errorf("unexpected name %q: %v", v, err)
+	}
+	reRaw, err := NewMarshaler(prefixed, ConsulExportFormat, 2).Marshal()
+	if err != nil {
+		t.Fatalf("marshal with prefix: %v", err)
+	}
+	if string(reRaw) != expRaw {
+		t.Errorf("result %q != expectation %q", string(reRaw), expRaw)
+	}
+
+	outside := NewKV(tree.New())
+	outside.AddPrefix("other")
+	if err := NewUnmarshaler(outside, ConsulExportFormat).Unmarshal(raw); err == nil {
+		t.Errorf("expected error for keys out of prefix")
+	}
+}
+
+func TestKV_AddPrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		expKeys []string
+	}{
+		{prefix: "service", expKeys: []string{"service/name"}},
+		{prefix: "service/", expKeys: []string{"service/name"}},
+		// empty prefix is the root, keys are stored with the leading separator
+		{prefix: "", expKeys: []string{"/name"}},
+	}
+
+	for _, tc := range tests {
+		kv := NewKV(tree.New())
+		if err := NewUnmarshaler(kv, YAMLFormat).Unmarshal([]byte("name: cimp\n")); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		kv.AddPrefix(tc.prefix)
+
+		flat, err := kv.flatten()
+		if err != nil {
+			t.Fatalf("flatten: %v", err)
+		}
+		var keys []string
+		for key := range flat {
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, tc.expKeys) {
+			t.Errorf("prefix %q: keys %q != expectation %q", tc.prefix, keys, tc.expKeys)
+		}
+	}
+}
+
+func TestKV_KeyNaming(t *testing.T) {
+	kv := NewKV(tree.New(), WithKeyNaming(tree.VerbatimNaming))
+	if err := NewUnmarshaler(kv, YAMLFormat).Unmarshal([]byte("server.http-port: 8080\nHardBranch: {a: 1}\n")); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	flat, err := kv.flatten()
+	if err != nil {
+		t.Fatalf("flatten: %v", err)
+	}
+	expFlat := map[string]string{"server.http-port": "8080", "HardBranch/a": "1"}
+	if !reflect.DeepEqual(flat, expFlat) {
+		t.Errorf("result %v != expectation %v", flat, expFlat)
+	}
+
+	if cloned, err := kv.DeepClone().flatten(); err != nil || !reflect.DeepEqual(cloned, expFlat) {
+		t.Errorf("result of clone %v != expectation %v: %v", cloned, expFlat, err)
+	}
+}
+
+func TestKV_FlattenScalars(t *testing.T) {
+	kv := newTestKV(t, YAMLFormat, "float: 1.0\nexp: 1.5e+30\nint: 1\nbool: true\nempty: null\n")
+
+	flat, err := kv.flatten()
+	if err != nil {
+		t.Fatalf("flatten: %v", err)
+	}
+	expFlat := map[string]string{
+		"service/float": "1.0",
+		"service/exp":   "1.5e+30",
+		"service/int":   "1",
+		"service/bool":  "true",
+		"service/empty": "",
+	}
+	if !reflect.DeepEqual(flat, expFlat) {
+		t.Errorf("result %v != expectation %v", flat, expFlat)
+	}
+}
+
+func TestKV_ConvertTreeNames(t *testing.T) {
+	kv := NewKV(tree.New(), WithKeyNaming(tree.KebabCaseNaming))
+	if err := NewUnmarshaler(kv, YAMLFormat).Unmarshal([]byte("fooBar: {innerKey: 1}\n")); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if err := kv.ConvertTreeNamesToCamelCase(); err != nil {
+		t.Fatalf("convert tree names: %v", err)
+	}
+	if _, ok := kv.tree.Content["foo-bar"].(*tree.Tree).Content["inner-key"]; !ok {
+		t.Errorf("names aren't converted by the key naming: %v", kv.tree.Order)
+	}
+	if !kv.Exists("foo-bar/inner-key") {
+		t.Errorf("full key isn't found after conversion")
+	}
+}
+
+func TestKV_ConvertTreeNamesCollisions(t *testing.T) {
+	// the tree keeps names as is, KV converts them to snake case by default
+	verbatimTree := tree.New(tree.WithKeyNaming(tree.VerbatimNaming))
+	if err := yaml.Unmarshal([]byte("fooBar: 1\nfoo-bar: 2\nbaz: 3\n"), verbatimTree); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	kv := NewKV(verbatimTree)
+
+	err := kv.ConvertTreeNamesToCamelCase()
+	var collisionErr *tree.CollisionError
+	if !errors.As(err, &collisionErr) {
+		t.Fatalf("expected collision error, got %v", err)
+	}
+	if c := collisionErr.Collisions; len(c) != 1 || c[0].FullKey != "foo_bar" || !reflect.DeepEqual(c[0].Names, []string{"fooBar", "foo-bar"}) {
+		t.Errorf("unexpected collisions %+v", c)
+	}
+	if !kv.Exists("fooBar") || !kv.Exists("foo-bar") {
+		t.Errorf("tree is changed after collision")
+	}
+}
@@ -23,21 +23,54 @@ type kvMarshaler struct {
 	kv           *KV
 	format       FileFormat
 	indentSpaces int
+	xmlConfig    tree.XMLConfig
+	// yamlDocumentKey is a key of YAML documents which values are used as their sub-prefixes
+	yamlDocumentKey string
 }
 
-func NewMarshaler(kv *KV, format FileFormat, indentSpaces int) Marshaler {
-	return &kvMarshaler{
+// MarshalerOption changes format specific settings of marshalers and unmarshalers.
+type MarshalerOption func(m *kvMarshaler)
+
+// WithXMLConfig sets naming of XML attributes and texts, tree.DefaultXMLConfig is used by default.
+func WithXMLConfig(cfg tree.XMLConfig) MarshalerOption {
+	return func(m *kvMarshaler) {
+		m.xmlConfig = cfg
+	}
+}
+
+// WithYAMLDocumentKey imports every document of multi-document YAML under the sub-prefix taken from the key value.
+// By default documents are merged in order.
+func WithYAMLDocumentKey(key string) MarshalerOption {
+	return func(m *kvMarshaler) {
+		m.yamlDocumentKey = key
+	}
+}
+
+func NewMarshaler(kv *KV, format FileFormat, indentSpaces int, opts ...MarshalerOption) Marshaler {
+	m := &kvMarshaler{
 		kv:           kv,
 		format:       format,
 		indentSpaces: indentSpaces,
+		xmlConfig:    tree.DefaultXMLConfig,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
 }
 
-func NewUnmarshaler(kv *KV, format FileFormat) Unmarshaler {
-	return &kvMarshaler{
-		kv:     kv,
-		format: format,
+func NewUnmarshaler(kv *KV, format FileFormat, opts ...MarshalerOption) Unmarshaler {
+	m := &kvMarshaler{
+		kv:        kv,
+		format:    format,
+		xmlConfig: tree.DefaultXMLConfig,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
 }
 
 func (m *kvMarshaler) Marshal() ([]byte, error) {
@@ -48,13 +81,52 @@ func (m *kvMarshaler) Marshal() ([]byte, error) {
 
 	switch m.format {
 	case YAMLFormat:
-		yamlEncoder := yaml.NewEncoder(&rawBuf)
-		yamlEncoder.SetIndent(m.indentSpaces)
-		err = yamlEncoder.Encode(m.kv.tree)
+		var doc *yaml.Node
+		if doc, err = m.kv.tree.MarshalYAMLDocument(); err == nil {
+			yamlEncoder := yaml.NewEncoder(&rawBuf)
+			yamlEncoder.SetIndent(m.indentSpaces)
+			err = yamlEncoder.Encode(doc)
+		}
+	case JSON5Format:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalJSON5(m.indentSpaces)
+		rawBuf.Write(raw)
 	case JSONFormat:
 		jsonEncoder := json.NewEncoder(&rawBuf)
 		jsonEncoder.SetIndent("", strings.Repeat(" ", m.indentSpaces))
 		err = jsonEncoder.Encode(m.kv.tree)
+	case EDNFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalEDN(m.indentSpaces)
+		rawBuf.Write(raw)
+	case TOMLFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalTOML()
+		rawBuf.Write(raw)
+	case HCLFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalHCL(m.indentSpaces)
+		rawBuf.Write(raw)
+	case PropertiesFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalProperties()
+		rawBuf.Write(raw)
+	case DotenvFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalDotenv()
+		rawBuf.Write(raw)
+	case INIFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalINI()
+		rawBuf.Write(raw)
+	case XMLFormat:
+		var raw []byte
+		raw, err = m.kv.tree.MarshalXMLDocument(m.indentSpaces, m.xmlConfig)
+		rawBuf.Write(raw)
+	case ConsulExportFormat:
+		var raw []byte
+		raw, err = marshalConsulExport(m.kv, m.indentSpaces)
+		rawBuf.Write(raw)
 	default:
 		return nil, fmt.Errorf("unsupported marshal format: %v", m.format)
 	}
@@ -67,15 +139,41 @@ func (m *kvMarshaler) Marshal() ([]byte, error) {
 }
 
 func (m *kvMarshaler) Unmarshal(raw []byte) error {
+	if m.kv.tree == nil {
+		m.kv.tree = tree.New(tree.WithKeyNaming(m.kv.naming))
+	}
+
 	var err error
 	switch m.format {
 	case JSONFormat:
 		err = json.Unmarshal(raw, &m.kv.tree)
 	case YAMLFormat:
-		err = yaml.Unmarshal(raw, &m.kv.tree)
+		err = m.kv.tree.UnmarshalYAMLDocuments(raw, m.yamlDocumentKey)
+	case EDNFormat:
+		err = m.kv.tree.UnmarshalEDN(raw)
+	case TOMLFormat:
+		err = m.kv.tree.UnmarshalTOML(raw)
+	case HCLFormat:
+		err = m.kv.tree.UnmarshalHCL(raw)
+	case PropertiesFormat:
+		err = m.kv.tree.UnmarshalProperties(raw)
+	case DotenvFormat:
+		err = m.kv.tree.UnmarshalDotenv(raw)
+	case INIFormat:
+		err = m.kv.tree.UnmarshalINI(raw)
+	case XMLFormat:
+		err = m.kv.tree.UnmarshalXMLDocument(raw, m.xmlConfig)
+	case JSON5Format:
+		err = m.kv.tree.UnmarshalJSON5(raw)
+	case ConsulExportFormat:
+		err = unmarshalConsulExport(m.kv, raw)
 	default:
 		return fmt.Errorf("unsupported unmarshal format: %v", m.format)
 	}
+	if err == nil {
+		// keys of all formats must stay unique in KV after conversion of names
+		err = m.kv.tree.CheckKeyCollisions(nil)
+	}
 
 	if err != nil {
 		return fmt.Errorf("%s-unmarshal of KV: %w", m.format, err)
@@ -0,0 +1,79 @@
+package cimp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemStorage keeps keys in memory. It's useful for tests and as a base for other simple storages.
+type MemStorage struct {
+	mu   sync.RWMutex
+	data map[string]string
+}
+
+func NewMemStorage() *MemStorage {
+	return &MemStorage{
+		data: make(map[string]string),
+	}
+}
+
+func (ms *MemStorage) Save(kv *KV) error {
+	flat, err := kv.flatten()
+	if err != nil {
+		return fmt.Errorf("flatten KV: %w", err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for key, value := range flat {
+		ms.data[key] = value
+	}
+
+	return nil
+}
+
+func (ms *MemStorage) Load(prefix string) (*KV, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return loadFromFlat(ms.data, prefix)
+}
+
+func (ms *MemStorage) Delete(kv *KV) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for key := range kv.idx {
+		delete(ms.data, kv.globalPrefix+key)
+	}
+
+	return nil
+}
+
+func (ms *MemStorage) List(prefix string) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var keys []string
+	for key := range ms.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Get returns a value stored by the full key.
+func (ms *MemStorage) Get(key string) (string, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	value, ok := ms.data[key]
+
+	return value, ok
+}
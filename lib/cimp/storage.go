@@ -2,78 +2,92 @@ package cimp
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 
-	"github.com/hashicorp/consul/api"
+	"github.com/humans-group/cimp/lib/tree"
 )
 
-type Config struct {
-	Address string
+// Storage is a KV storage which keys can be imported to and exported from.
+type Storage interface {
+	// Save writes all leaves of the KV by their full keys with the global prefix.
+	Save(kv *KV) error
+	// Load reads all keys stored under the prefix and builds KV from them.
+	Load(prefix string) (*KV, error)
+	// Delete removes all keys of the KV from the storage.
+	Delete(kv *KV) error
+	// List returns sorted full keys stored under the prefix.
+	List(prefix string) ([]string, error)
 }
 
-type ConsulStorage struct {
-	client *api.Client
-}
+var (
+	_ Storage = (*ConsulStorage)(nil)
+	_ Storage = (*EtcdStorage)(nil)
+	_ Storage = (*FileStorage)(nil)
+	_ Storage = (*DirStorage)(nil)
+	_ Storage = (*MemStorage)(nil)
+)
 
-const consulTransactionLimit = 64
+const (
+	consulScheme = "consul"
+	etcdScheme   = "etcd"
+	fileScheme   = "file"
+	dirScheme    = "dir"
+	memScheme    = "mem"
+)
 
-func NewStorage(cfg Config) (*ConsulStorage, error) {
-	clientCfg := api.DefaultConfig()
-	clientCfg.Address = cfg.Address
+// NewStorageFromURL creates a storage by URL scheme:
+// consul://address:port[?nulls=empty|skip|delete], etcd://address:port[,address:port], file:///path/to/file.yaml,
+// dir:///path/to/directory or mem://.
+// Address without scheme is treated as consul endpoint.
+func NewStorageFromURL(rawURL string) (Storage, error) {
+	if !strings.Contains(rawURL, "://") {
+		return NewStorage(Config{Address: rawURL})
+	}
 
-	client, err := api.NewClient(clientCfg)
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("create consul client: %w", err)
+		return nil, fmt.Errorf("parse storage URL %q: %w", rawURL, err)
 	}
 
-	return &ConsulStorage{
-		client: client,
-	}, nil
+	switch u.Scheme {
+	case consulScheme:
+		return NewStorage(Config{Address: u.Host, NullPolicy: NullPolicy(u.Query().Get("nulls"))})
+	case etcdScheme:
+		return NewEtcdStorage(EtcdConfig{Endpoints: strings.Split(u.Host, ",")})
+	case fileScheme:
+		return NewFileStorage(u.Host + u.Path)
+	case dirScheme:
+		return NewDirStorage(u.Host + u.Path)
+	case memScheme:
+		return NewMemStorage(), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme %q", u.Scheme)
+	}
 }
 
-func (cs *ConsulStorage) Save(kv *KV) error {
-	var ops api.TxnOps
-
-	var i int
-	for key, path := range kv.idx {
-		i++
-		leaf, err := kv.tree.Get(path)
-		if err != nil {
-			return fmt.Errorf("get key %q value from tree: %w", key, err)
-		}
-
-		op := &api.TxnOp{
-			KV: &api.KVTxnOp{
-				Verb:  api.KVSet,
-				Key:   kv.globalPrefix + key,
-				Value: []byte(fmt.Sprint(leaf.Value)),
-			},
-		}
-		ops = append(ops, op)
-
-		if len(ops) == consulTransactionLimit || i == len(kv.idx) {
-			if ok, _, _, err := cs.client.Txn().Txn(ops, nil); !ok {
-				return fmt.Errorf("execute consul SET-transaction: %w", err)
-			}
-			ops = nil
-		}
+// loadFromFlat builds KV from full keys with values which are stored under the prefix.
+func loadFromFlat(flat map[string]string, prefix string) (*KV, error) {
+	kv := NewKV(tree.New())
+	// empty prefix loads the whole storage, AddPrefix would turn it to the root "/"
+	if len(prefix) > 0 {
+		kv.AddPrefix(prefix)
 	}
 
-	if len(ops) > 0 {
-		if ok, _, _, err := cs.client.Txn().Txn(ops, nil); !ok {
-			return fmt.Errorf("execute consul SET-transaction: %w", err)
+	relative := make(map[string]string)
+	for key, value := range flat {
+		if !strings.HasPrefix(key, kv.globalPrefix) || len(key) == len(kv.globalPrefix) {
+			continue
 		}
+		relative[key[len(kv.globalPrefix):]] = value
 	}
 
-	return nil
-}
-
-func (cs *ConsulStorage) Delete(kv *KV) error {
-	for k := range kv.idx {
-		_, err := cs.client.KV().Delete(k, nil)
-		if err != nil {
-			return fmt.Errorf("delete %q from consul: %w", k, err)
-		}
+	// stored keys are already final, so they're kept as is
+	t, err := tree.NewFromFlat(relative, tree.WithKeyNaming(tree.VerbatimNaming))
+	if err != nil {
+		return nil, fmt.Errorf("build tree from stored keys: %w", err)
 	}
+	kv.SetTree(t)
 
-	return nil
+	return kv, nil
 }
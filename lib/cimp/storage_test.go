@@ -0,0 +1,134 @@
+package cimp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/humans-group/cimp/lib/tree"
+)
+
+func TestStorages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cimp")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storages := map[string]string{
+		"mem":  "mem://",
+		"file": "file://" + filepath.Join(dir, "storage.yaml"),
+		"dir":  "dir://" + filepath.Join(dir, "storage"),
+	}
+
+	for name, rawURL := range storages {
+		t.Run(name, func(t *testing.T) {
+			storage, err := NewStorageFromURL(rawURL)
+			if err != nil {
+				t.Fatalf("create storage: %v", err)
+			}
+
+			kv := newTestKV(t, YAMLFormat, `
+name: cimp
+hosts: [a, b]
+`)
+			if err := storage.Save(kv); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+
+			keys, err := storage.List("service/")
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			expKeys := []string{"service/hosts/0", "service/hosts/1", "service/name"}
+			if !reflect.DeepEqual(keys, expKeys) {
+				t.Errorf("keys %v != expectation %v", keys, expKeys)
+			}
+
+			loadedKV, err := storage.Load("service")
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			changes, err := Diff(loadedKV, kv)
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			if len(changes) > 0 {
+				t.Errorf("loaded KV differs from saved one: %+v", changes)
+			}
+
+			if err := storage.Delete(kv); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			keys, err = storage.List("")
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(keys) > 0 {
+				t.Errorf("keys %v are left after deletion", keys)
+			}
+		})
+	}
+}
+
+func TestStorages_EscapedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cimp")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storages := map[string]string{
+		"mem":  "mem://",
+		"file": "file://" + filepath.Join(dir, "storage.yaml"),
+		"dir":  "dir://" + filepath.Join(dir, "storage"),
+	}
+
+	for name, rawURL := range storages {
+		t.Run(name, func(t *testing.T) {
+			storage, err := NewStorageFromURL(rawURL)
+			if err != nil {
+				t.Fatalf("create storage: %v", err)
+			}
+
+			kv := NewKV(tree.New(), WithKeyNaming(tree.VerbatimNaming))
+			if err := NewUnmarshaler(kv, YAMLFormat).Unmarshal([]byte(`
+"a/b": 1
+a: {b: 2}
+"100%": 3
+`)); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			kv.AddPrefix("service")
+			if err := storage.Save(kv); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+
+			keys, err := storage.List("service/")
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			expKeys := []string{"service/100%25", "service/a%2Fb", "service/a/b"}
+			if !reflect.DeepEqual(keys, expKeys) {
+				t.Errorf("keys %v != expectation %v", keys, expKeys)
+			}
+
+			loadedKV, err := storage.Load("service")
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if _, ok := loadedKV.tree.Content["a/b"]; !ok {
+				t.Errorf("escaped name isn't restored, got names %v", loadedKV.tree.Order)
+			}
+			changes, err := Diff(loadedKV, kv)
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			if len(changes) > 0 {
+				t.Errorf("loaded KV differs from saved one: %+v", changes)
+			}
+		})
+	}
+}
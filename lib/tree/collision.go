@@ -0,0 +1,116 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a location of the key in the source document. Line and column are known for YAML,
+// offset of the key end in bytes is known for JSON.
+type Position struct {
+	Line   int
+	Column int
+	Offset int64
+}
+
+// KeyCollision describes sibling names which are converted to the same full key.
+type KeyCollision struct {
+	FullKey   string
+	Names     []string
+	Positions []Position
+}
+
+// CollisionError is returned when names of sibling nodes are converted to the same full keys.
+type CollisionError struct {
+	Collisions []KeyCollision
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	case p.Offset > 0:
+		return fmt.Sprintf("offset %d", p.Offset)
+	default:
+		return "unknown position"
+	}
+}
+
+func (e *CollisionError) Error() string {
+	collisions := make([]string, 0, len(e.Collisions))
+	for _, c := range e.Collisions {
+		names := make([]string, 0, len(c.Names))
+		for i, name := range c.Names {
+			names = append(names, fmt.Sprintf("%q (%s)", name, c.Positions[i]))
+		}
+		collisions = append(collisions, fmt.Sprintf("%q from %s", c.FullKey, strings.Join(names, ", ")))
+	}
+
+	return "key collisions: " + strings.Join(collisions, "; ")
+}
+
+// CheckKeyCollisions returns *CollisionError with all sibling names of the tree which are converted
+// to the same full key by the naming. If the naming is nil, naming of the tree is used.
+func (mt *Tree) CheckKeyCollisions(naming KeyNaming) error {
+	if naming == nil {
+		naming = mt.KeyNaming()
+	}
+
+	var collisions []KeyCollision
+	collectCollisions(mt, naming, &collisions)
+	if len(collisions) > 0 {
+		return &CollisionError{Collisions: collisions}
+	}
+
+	return nil
+}
+
+func collectCollisions(m Marshalable, naming KeyNaming, collisions *[]KeyCollision) {
+	switch item := m.(type) {
+	case *Tree:
+		var (
+			order  []string
+			groups = make(map[string][]string)
+		)
+		for _, name := range item.Order {
+			fullKey := naming.makeNodeFullKey(item.FullKey, name, item.Content[name])
+			if _, ok := groups[fullKey]; !ok {
+				order = append(order, fullKey)
+			}
+			groups[fullKey] = append(groups[fullKey], name)
+		}
+		for _, fullKey := range order {
+			names := groups[fullKey]
+			if len(names) < 2 {
+				continue
+			}
+			collision := KeyCollision{FullKey: fullKey, Names: names}
+			for _, name := range names {
+				collision.Positions = append(collision.Positions, position(item.Content[name]))
+			}
+			*collisions = append(*collisions, collision)
+		}
+
+		for _, name := range item.Order {
+			collectCollisions(item.Content[name], naming, collisions)
+		}
+	case *Branch:
+		for _, element := range item.Content {
+			collectCollisions(element, naming, collisions)
+		}
+	}
+}
+
+// position returns location of the key of the node in the source document.
+func position(m Marshalable) Position {
+	switch item := m.(type) {
+	case *Tree:
+		return item.pos
+	case *Branch:
+		return item.pos
+	case *Leaf:
+		return item.pos
+	default:
+		return Position{}
+	}
+}
@@ -0,0 +1,132 @@
+package tree
+
+// ChangeType is a kind of difference between nodes of two trees.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+	// ChangeTypeChanged means that kinds of nodes or scalar types of leaves are different.
+	ChangeTypeChanged ChangeType = "type_changed"
+	// ChangeResized means that branches have different lengths, their elements are compared too.
+	ChangeResized ChangeType = "resized"
+)
+
+// NodeKind is a kind of tree node.
+type NodeKind string
+
+const (
+	TreeKind   NodeKind = "tree"
+	BranchKind NodeKind = "branch"
+	LeafKind   NodeKind = "leaf"
+)
+
+// Change describes difference of one node between two trees. Old is nil for added nodes, New is nil for removed ones.
+type Change struct {
+	Type    ChangeType  `json:"type"`
+	FullKey string      `json:"key"`
+	OldKind NodeKind    `json:"old_kind,omitempty"`
+	NewKind NodeKind    `json:"new_kind,omitempty"`
+	Old     Marshalable `json:"old,omitempty"`
+	New     Marshalable `json:"new,omitempty"`
+}
+
+// Diff walks both trees and returns changes which turn the tree a into the tree b. Nodes are matched by full keys.
+// Added and removed sub-trees are reported once without their children, nodes of different kinds aren't compared deeper.
+// Changes are ordered as nodes of a, added nodes follow nodes of their parent in a. Comments are ignored.
+func Diff(a, b *Tree) []Change {
+	var changes []Change
+	diffTrees(a, b, &changes)
+
+	return changes
+}
+
+func diffNodes(a, b Marshalable, changes *[]Change) {
+	aKind, bKind := kindOf(a), kindOf(b)
+	if aKind != bKind {
+		*changes = append(*changes, newChange(ChangeTypeChanged, b.GetFullKey(), a, b))
+		return
+	}
+
+	switch aItem := a.(type) {
+	case *Tree:
+		diffTrees(aItem, b.(*Tree), changes)
+	case *Branch:
+		diffBranches(aItem, b.(*Branch), changes)
+	case *Leaf:
+		bLeaf := b.(*Leaf)
+		switch {
+		case aItem.Type() != bLeaf.Type():
+			*changes = append(*changes, newChange(ChangeTypeChanged, b.GetFullKey(), a, b))
+		case aItem.Type() != NullType && FormatScalar(aItem.Value) != FormatScalar(bLeaf.Value):
+			*changes = append(*changes, newChange(ChangeModified, b.GetFullKey(), a, b))
+		}
+	}
+}
+
+func diffTrees(a, b *Tree, changes *[]Change) {
+	bNames := make(map[string]string, len(b.Order))
+	for _, name := range b.Order {
+		bNames[b.Content[name].GetFullKey()] = name
+	}
+
+	aKeys := make(map[string]bool, len(a.Order))
+	for _, name := range a.Order {
+		aItem := a.Content[name]
+		aKeys[aItem.GetFullKey()] = true
+		bName, ok := bNames[aItem.GetFullKey()]
+		if !ok {
+			*changes = append(*changes, newChange(ChangeRemoved, aItem.GetFullKey(), aItem, nil))
+			continue
+		}
+		diffNodes(aItem, b.Content[bName], changes)
+	}
+
+	for _, name := range b.Order {
+		bItem := b.Content[name]
+		if !aKeys[bItem.GetFullKey()] {
+			*changes = append(*changes, newChange(ChangeAdded, bItem.GetFullKey(), nil, bItem))
+		}
+	}
+}
+
+func diffBranches(a, b *Branch, changes *[]Change) {
+	if len(a.Content) != len(b.Content) {
+		*changes = append(*changes, newChange(ChangeResized, b.FullKey, a, b))
+	}
+
+	for i := range a.Content {
+		if i >= len(b.Content) {
+			*changes = append(*changes, newChange(ChangeRemoved, a.Content[i].GetFullKey(), a.Content[i], nil))
+			continue
+		}
+		diffNodes(a.Content[i], b.Content[i], changes)
+	}
+	for i := len(a.Content); i < len(b.Content); i++ {
+		*changes = append(*changes, newChange(ChangeAdded, b.Content[i].GetFullKey(), nil, b.Content[i]))
+	}
+}
+
+func newChange(changeType ChangeType, fullKey string, a, b Marshalable) Change {
+	change := Change{Type: changeType, FullKey: fullKey}
+	if a != nil {
+		change.Old, change.OldKind = a, kindOf(a)
+	}
+	if b != nil {
+		change.New, change.NewKind = b, kindOf(b)
+	}
+
+	return change
+}
+
+func kindOf(m Marshalable) NodeKind {
+	switch m.(type) {
+	case *Tree:
+		return TreeKind
+	case *Branch:
+		return BranchKind
+	default:
+		return LeafKind
+	}
+}
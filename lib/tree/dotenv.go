@@ -0,0 +1,174 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dotenvSep separates nested names, single underscores are parts of names as usual in env files.
+const dotenvSep = "__"
+
+var (
+	dotenvKeyRegexp       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	dotenvBareValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_./:@,+-]*$`)
+)
+
+// UnmarshalDotenv fills the tree from dotenv document. Keys are lowercased and split by double underscores
+// into nested sub-trees, keys which would have empty parts are kept whole. All values are kept as strings.
+func (mt *Tree) UnmarshalDotenv(raw []byte) error {
+	mt.clearValues()
+
+	root := newFlatNode()
+	rest := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	for lineNum := 1; rest != ""; lineNum++ {
+		var line string
+		if end := strings.IndexByte(rest, '\n'); end >= 0 {
+			line, rest = rest[:end], rest[end+1:]
+		} else {
+			line, rest = rest, ""
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: missing '=' after key", lineNum)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !dotenvKeyRegexp.MatchString(key) {
+			return fmt.Errorf("line %d: invalid key %q", lineNum, key)
+		}
+
+		value := strings.TrimLeft(line[eq+1:], " \t")
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			// quoted values may span several lines
+			quoted := value
+			for !hasClosingQuote(quoted) && rest != "" {
+				var next string
+				if end := strings.IndexByte(rest, '\n'); end >= 0 {
+					next, rest = rest[:end], rest[end+1:]
+				} else {
+					next, rest = rest, ""
+				}
+				quoted += "\n" + next
+				lineNum++
+			}
+			var err error
+			if value, err = unquoteDotenv(quoted); err != nil {
+				return fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		} else {
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = value[:comment]
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		key = strings.ToLower(key)
+		path, err := splitFlatKey(key, dotenvSep)
+		if err != nil {
+			path = Path{key}
+		}
+		root.add(path, value)
+	}
+
+	return root.fill(mt)
+}
+
+// MarshalDotenv writes leaves of the tree as dotenv variables. Names are joined by double underscores
+// and uppercased, null values are written as empty.
+func (mt *Tree) MarshalDotenv() ([]byte, error) {
+	var buf bytes.Buffer
+	err := walkLeaves(mt, nil, func(path Path, leaf *Leaf) error {
+		key := strings.ToUpper(strings.Join(path, dotenvSep))
+		if !dotenvKeyRegexp.MatchString(key) {
+			return fmt.Errorf("key %q can't be used as variable name", key)
+		}
+		// names with double or trailing underscores would be split differently on reading
+		readPath, err := splitFlatKey(strings.ToLower(key), dotenvSep)
+		if err != nil {
+			readPath = Path{strings.ToLower(key)}
+		}
+		if len(readPath) != len(path) {
+			return fmt.Errorf("key %q can't be split back to names %q", key, path)
+		}
+		buf.WriteString(key)
+		buf.WriteRune('=')
+		buf.WriteString(quoteDotenv(flatValue(leaf)))
+		buf.WriteRune('\n')
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// hasClosingQuote reports whether the value starting with a quote has the closing one.
+func hasClosingQuote(value string) bool {
+	quote := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && quote == '"':
+			i++
+		case value[i] == quote:
+			return true
+		}
+	}
+
+	return false
+}
+
+// unquoteDotenv returns content of the quoted value. Escapes are handled only in double quotes,
+// text after the closing quote may be only a comment.
+func unquoteDotenv(value string) (string, error) {
+	quote := value[0]
+
+	var buf strings.Builder
+	i := 1
+	for ; i < len(value) && value[i] != quote; i++ {
+		if value[i] != '\\' || quote != '"' || i+1 == len(value) {
+			buf.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		default:
+			buf.WriteByte(value[i])
+		}
+	}
+	if i == len(value) {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+
+	if tail := strings.TrimSpace(value[i+1:]); tail != "" && tail[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after quoted value", tail)
+	}
+
+	return buf.String(), nil
+}
+
+// quoteDotenv writes safe values as is and others in double quotes with escapes.
+func quoteDotenv(value string) string {
+	if dotenvBareValueRegexp.MatchString(value) {
+		return value
+	}
+
+	return dotenvEscapes.quote(value)
+}
+
+// dotenvEscapes escape also characters of shell expansions.
+var dotenvEscapes = stringEscapes{runes: mergeEscapes(quotedEscapes, map[rune]string{'$': `\$`, '`': "\\`"})}
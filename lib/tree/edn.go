@@ -0,0 +1,439 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EDN keywords are kept in leaves as strings without the leading colon with this tag and they're written back
+// as keywords, so strings and keywords stay distinct. Map keys are names of children, so keywords are used
+// for them without the colon.
+const ednKeywordTag = "!edn/keyword"
+
+var (
+	ednIntRegexp     = regexp.MustCompile(`^[+-]?\d+N?$`)
+	ednFloatRegexp   = regexp.MustCompile(`^[+-]?\d+(\.\d*)?([eE][+-]?\d+)?M?$`)
+	ednKeywordRegexp = regexp.MustCompile(`^:[A-Za-z*!?_<>=+\-.][A-Za-z0-9*!?_<>=+\-.:#'/]*$`)
+)
+
+var ednCharNames = map[string]string{
+	"newline":   "\n",
+	"space":     " ",
+	"tab":       "\t",
+	"return":    "\r",
+	"backspace": "\b",
+	"formfeed":  "\f",
+}
+
+type ednParser struct {
+	raw []byte
+	pos int
+}
+
+// UnmarshalEDN fills the tree from EDN map, order of keys is preserved.
+// Vectors, lists and sets become branches, tagged elements are read without tags.
+func (mt *Tree) UnmarshalEDN(raw []byte) error {
+	mt.clearValues()
+	p := &ednParser{raw: raw}
+
+	p.skipSpaces()
+	if !p.consume('{') {
+		return p.errorf("EDN document must be a map")
+	}
+	if err := p.parseMap(mt); err != nil {
+		return err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.raw) {
+		return p.errorf("unexpected content after the root map")
+	}
+
+	return nil
+}
+
+// MarshalEDN writes the tree as EDN map. If indent is zero, the map is written in one line.
+func (mt *Tree) MarshalEDN(indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeEDN(&buf, mt, indent, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteRune('\n')
+
+	return buf.Bytes(), nil
+}
+
+func (p *ednParser) parseMap(mt *Tree) error {
+	for {
+		p.skipSpaces()
+		if p.consume('}') {
+			return nil
+		}
+		if p.pos >= len(p.raw) {
+			return p.errorf("EDN map must be closed with '}'")
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+
+		p.skipSpaces()
+		child, err := p.parseValue(key, mt.FullKey)
+		if err != nil {
+			return fmt.Errorf("unmarshal %q: %w", key, err)
+		}
+		mt.AddOrReplaceDirectly(key, child)
+	}
+}
+
+func (p *ednParser) parseSeq(mb *Branch, closing byte) error {
+	for i := 0; ; i++ {
+		p.skipSpaces()
+		if p.consume(closing) {
+			return nil
+		}
+		if p.pos >= len(p.raw) {
+			return p.errorf("EDN collection must be closed with '%c'", closing)
+		}
+
+		name := strconv.Itoa(i)
+		child, err := p.parseValue(name, mb.FullKey)
+		if err != nil {
+			return fmt.Errorf("unmarshal #%d: %w", i, err)
+		}
+		mb.Add(child)
+	}
+}
+
+func (p *ednParser) parseKey() (string, error) {
+	if p.pos < len(p.raw) && p.raw[p.pos] == '"' {
+		return p.parseString()
+	}
+
+	token := p.readToken()
+	if len(token) == 0 {
+		return "", p.errorf("map key must be a scalar")
+	}
+	if strings.HasPrefix(token, ":") {
+		return token[1:], nil
+	}
+
+	value, err := p.parseScalar(token)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatScalar(value), nil
+}
+
+func (p *ednParser) parseValue(name, parentFullKey string) (Marshalable, error) {
+	if p.pos >= len(p.raw) {
+		return nil, p.errorf("unexpected end of EDN")
+	}
+
+	switch p.raw[p.pos] {
+	case '{':
+		p.pos++
+		subTree := NewSubTree(name, parentFullKey)
+		return subTree, p.parseMap(subTree)
+	case '[', '(':
+		closing := byte(']')
+		if p.raw[p.pos] == '(' {
+			closing = ')'
+		}
+		p.pos++
+		branch := NewBranch(name, parentFullKey)
+		return branch, p.parseSeq(branch, closing)
+	case '#':
+		if p.pos+1 < len(p.raw) && p.raw[p.pos+1] == '{' {
+			p.pos += 2
+			branch := NewBranch(name, parentFullKey)
+			return branch, p.parseSeq(branch, '}')
+		}
+		// tagged element, the tag is skipped
+		p.pos++
+		if tag := p.readToken(); len(tag) == 0 {
+			return nil, p.errorf("empty tag")
+		}
+		p.skipSpaces()
+		return p.parseValue(name, parentFullKey)
+	case '"':
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = value
+		return leaf, nil
+	case '}', ']', ')':
+		return nil, p.errorf("unexpected '%c'", p.raw[p.pos])
+	}
+
+	token := p.readToken()
+	leaf := NewLeaf(name, parentFullKey)
+	if ednKeywordRegexp.MatchString(token) {
+		leaf.Value = token[1:]
+		leaf.Tag = ednKeywordTag
+		return leaf, nil
+	}
+	value, err := p.parseScalar(token)
+	if err != nil {
+		return nil, err
+	}
+	leaf.SetValue(value)
+
+	return leaf, nil
+}
+
+func (p *ednParser) parseScalar(token string) (interface{}, error) {
+	switch {
+	case len(token) == 0:
+		return nil, p.errorf("empty element")
+	case token == "nil":
+		return nil, nil
+	case token == "true":
+		return true, nil
+	case token == "false":
+		return false, nil
+	case token[0] == '\\':
+		if char, ok := ednCharNames[token[1:]]; ok {
+			return char, nil
+		}
+		if strings.HasPrefix(token, `\u`) && len(token) == 6 {
+			code, err := strconv.ParseUint(token[2:], 16, 32)
+			if err != nil {
+				return nil, p.errorf("incorrect unicode character %q", token)
+			}
+			return string(rune(code)), nil
+		}
+		return token[1:], nil
+	case ednIntRegexp.MatchString(token):
+		if value, err := strconv.ParseInt(strings.TrimSuffix(token, "N"), 10, 64); err == nil {
+			return value, nil
+		}
+		return token, nil
+	case ednFloatRegexp.MatchString(token):
+		value, err := strconv.ParseFloat(strings.TrimSuffix(token, "M"), 64)
+		if err != nil {
+			return token, nil
+		}
+		return value, nil
+	default:
+		// symbols and keywords which can't be written back
+		return token, nil
+	}
+}
+
+func (p *ednParser) parseString() (string, error) {
+	p.pos++ // opening quote
+	var sb strings.Builder
+	for p.pos < len(p.raw) {
+		c := p.raw[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if p.pos >= len(p.raw) {
+				return "", p.errorf("unterminated string")
+			}
+			escaped := p.raw[p.pos]
+			p.pos++
+			switch escaped {
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '\\', '"':
+				sb.WriteByte(escaped)
+			case 'u':
+				if p.pos+4 > len(p.raw) {
+					return "", p.errorf("incorrect unicode escape")
+				}
+				code, err := strconv.ParseUint(string(p.raw[p.pos:p.pos+4]), 16, 32)
+				if err != nil {
+					return "", p.errorf("incorrect unicode escape")
+				}
+				sb.WriteRune(rune(code))
+				p.pos += 4
+			default:
+				return "", p.errorf("unsupported escape '\\%c'", escaped)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+// readToken reads symbols, keywords, numbers and characters till the delimiter.
+func (p *ednParser) readToken() string {
+	start := p.pos
+	// character literal can be a delimiter itself, e.g. `\(`
+	if p.pos < len(p.raw) && p.raw[p.pos] == '\\' {
+		p.pos += 2
+	}
+	for p.pos < len(p.raw) && !isEDNDelimiter(p.raw[p.pos]) {
+		p.pos++
+	}
+	if p.pos > len(p.raw) {
+		p.pos = len(p.raw)
+	}
+
+	return string(p.raw[start:p.pos])
+}
+
+// skipSpaces skips whitespaces, commas, comments and discarded elements.
+func (p *ednParser) skipSpaces() {
+	for p.pos < len(p.raw) {
+		switch c := p.raw[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == ';':
+			for p.pos < len(p.raw) && p.raw[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '#' && p.pos+1 < len(p.raw) && p.raw[p.pos+1] == '_':
+			p.pos += 2
+			p.skipSpaces()
+			// discarded element is parsed to find its end
+			_, _ = p.parseValue("", "")
+		default:
+			return
+		}
+	}
+}
+
+func (p *ednParser) consume(c byte) bool {
+	if p.pos < len(p.raw) && p.raw[p.pos] == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *ednParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("EDN offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func isEDNDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', ',', ';', '"', '(', ')', '[', ']', '{', '}':
+		return true
+	default:
+		return false
+	}
+}
+
+func writeEDN(buf *bytes.Buffer, m Marshalable, indent, level int) error {
+	switch item := m.(type) {
+	case *Tree:
+		buf.WriteRune('{')
+		for i, name := range item.Order {
+			writeEDNSeparator(buf, indent, level+1, i == 0)
+			writeEDNKey(buf, name)
+			buf.WriteRune(' ')
+			if err := writeEDN(buf, item.Content[name], indent, level+1); err != nil {
+				return fmt.Errorf("marshal %q: %w", name, err)
+			}
+		}
+		if indent > 0 && len(item.Order) > 0 {
+			writeEDNSeparator(buf, indent, level, false)
+		}
+		buf.WriteRune('}')
+	case *Branch:
+		isAllLeafs := true
+		for _, child := range item.Content {
+			if _, ok := child.(*Leaf); !ok {
+				isAllLeafs = false
+				break
+			}
+		}
+		// slices of scalars are written in one line like in YAML
+		childIndent := indent
+		if isAllLeafs {
+			childIndent = 0
+		}
+
+		buf.WriteRune('[')
+		for i, child := range item.Content {
+			writeEDNSeparator(buf, childIndent, level+1, i == 0)
+			if err := writeEDN(buf, child, indent, level+1); err != nil {
+				return fmt.Errorf("marshal #%d: %w", i, err)
+			}
+		}
+		if childIndent > 0 && len(item.Content) > 0 {
+			writeEDNSeparator(buf, childIndent, level, false)
+		}
+		buf.WriteRune(']')
+	case *Leaf:
+		return writeEDNScalar(buf, item)
+	default:
+		return fmt.Errorf("unsupported type %T", m)
+	}
+
+	return nil
+}
+
+func writeEDNSeparator(buf *bytes.Buffer, indent, level int, isFirst bool) {
+	if indent > 0 {
+		buf.WriteRune('\n')
+		buf.WriteString(strings.Repeat(" ", indent*level))
+		return
+	}
+	if !isFirst {
+		buf.WriteRune(' ')
+	}
+}
+
+func writeEDNKey(buf *bytes.Buffer, name string) {
+	if ednKeywordRegexp.MatchString(":" + name) {
+		buf.WriteString(":" + name)
+		return
+	}
+	writeEDNString(buf, name)
+}
+
+func writeEDNScalar(buf *bytes.Buffer, leaf *Leaf) error {
+	switch v := leaf.Value.(type) {
+	case nil:
+		buf.WriteString("nil")
+	case string:
+		if leaf.Tag != ednKeywordTag {
+			writeEDNString(buf, v)
+			break
+		}
+		if !ednKeywordRegexp.MatchString(":" + v) {
+			return fmt.Errorf("%q can't be written as keyword", v)
+		}
+		buf.WriteString(":" + v)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("unsupported float value %v", v)
+		}
+		buf.WriteString(FormatScalar(v))
+	default:
+		buf.WriteString(FormatScalar(normalizeScalar(v)))
+	}
+
+	return nil
+}
+
+var ednEscapes = stringEscapes{runes: quotedEscapes, unicode: true}
+
+func writeEDNString(buf *bytes.Buffer, s string) {
+	buf.WriteString(ednEscapes.quote(s))
+}
@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// stringEscapes describe how a text format escapes special characters of strings.
+type stringEscapes struct {
+	// runes are written as their escape sequences
+	runes map[rune]string
+	// unicode enables \uXXXX escapes of other control characters, they're written as is otherwise
+	unicode bool
+	// ascii enables \uXXXX escapes of runes above 0x7E, runes above U+FFFF are written as surrogate pairs
+	ascii bool
+}
+
+// quotedEscapes are escapes of double quoted strings which are common for all formats.
+var quotedEscapes = map[rune]string{
+	'"':  `\"`,
+	'\\': `\\`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+}
+
+// mergeEscapes returns the base escapes with escapes of the format.
+func mergeEscapes(base, extra map[rune]string) map[rune]string {
+	runes := make(map[rune]string, len(base)+len(extra))
+	for r, escaped := range base {
+		runes[r] = escaped
+	}
+	for r, escaped := range extra {
+		runes[r] = escaped
+	}
+
+	return runes
+}
+
+func (e stringEscapes) escape(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		if escaped, ok := e.runes[r]; ok {
+			buf.WriteString(escaped)
+			continue
+		}
+		if e.unicode && (r < 0x20 || r == 0x7f) {
+			buf.WriteString(fmt.Sprintf(`\u%04X`, r))
+			continue
+		}
+		if e.ascii && r > 0x7e {
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				buf.WriteString(fmt.Sprintf(`\u%04X\u%04X`, r1, r2))
+				continue
+			}
+			buf.WriteString(fmt.Sprintf(`\u%04X`, r))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
+
+// quote returns the escaped string in double quotes.
+func (e stringEscapes) quote(s string) string {
+	return `"` + e.escape(s) + `"`
+}
@@ -0,0 +1,47 @@
+name = "cimp"
+port = 8080
+ratio = 0.5
+enabled = true
+hosts = ["a", "b", 3]
+tags = {
+  env = "prod"
+  "team name" = "core"
+}
+image = "${var.registry}/cimp"
+region = var.region
+
+service {
+  http {
+    web {
+      listen = 80
+
+      check {
+        path = "/health"
+      }
+
+      check {
+        path = "/ready"
+      }
+    }
+
+    api {
+      listen = 81
+    }
+  }
+}
+
+resource "aws_instance" "web" {
+  ami = "ami-1"
+}
+
+resource "aws_instance" "db" {
+  ami = "ami-2"
+}
+
+resource "aws_instance" "db" {
+  ami = "ami-3"
+}
+
+resource "aws_s3_bucket" "static files" {
+  acl = "private"
+}
@@ -0,0 +1,151 @@
+package tree
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// flatNode is an intermediate node used to rebuild a tree from flat full keys.
+type flatNode struct {
+	children map[string]*flatNode
+	order    []string
+	value    *string
+}
+
+// NewFromFlat builds a tree from pairs of full keys and values as they are stored in KV storages.
+// Sub-trees are reconstructed from separated keys, nodes with numeric sequential children become branches.
+// Escaped separators in parts of keys are unescaped in names.
+func NewFromFlat(pairs map[string]string, opts ...Option) (*Tree, error) {
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	root := newFlatNode()
+	for _, key := range keys {
+		root.add(SplitFullKey(key), pairs[key])
+	}
+
+	t := New(opts...)
+	if err := root.fill(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func newFlatNode() *flatNode {
+	return &flatNode{children: make(map[string]*flatNode)}
+}
+
+// add adds the value by the path, order of added paths is preserved.
+func (fn *flatNode) add(path Path, value string) {
+	fn.addNode(path).value = &value
+}
+
+// addNode returns the node by the path, missing nodes are created. Nodes without values and children become empty trees.
+func (fn *flatNode) addNode(path Path) *flatNode {
+	cur := fn
+	for _, name := range path {
+		child, ok := cur.children[name]
+		if !ok {
+			child = newFlatNode()
+			cur.children[name] = child
+			cur.order = append(cur.order, name)
+		}
+		cur = child
+	}
+
+	return cur
+}
+
+// fill adds children of the root node to the tree.
+func (fn *flatNode) fill(mt *Tree) error {
+	if fn.value != nil {
+		return fmt.Errorf("root can't have a value")
+	}
+
+	for _, name := range fn.order {
+		child, err := fn.children[name].build(name, mt.FullKey)
+		if err != nil {
+			return err
+		}
+		mt.AddOrReplaceDirectly(name, child)
+	}
+
+	return nil
+}
+
+func (fn *flatNode) build(name, parentFullKey string) (Marshalable, error) {
+	fullKey := MakeFullKey(parentFullKey, name)
+	if len(fn.children) == 0 && fn.value == nil {
+		return NewSubTree(name, parentFullKey), nil
+	}
+	if len(fn.children) == 0 {
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = *fn.value
+		return leaf, nil
+	}
+	if fn.value != nil {
+		return nil, fmt.Errorf("key %q has both value and nested keys", fullKey)
+	}
+
+	if fn.isBranch() {
+		branch := NewBranch(name, parentFullKey)
+		for i := 0; i < len(fn.children); i++ {
+			childName := strconv.Itoa(i)
+			child, err := fn.children[childName].build(childName, fullKey)
+			if err != nil {
+				return nil, err
+			}
+			branch.Add(child)
+		}
+		return branch, nil
+	}
+
+	subTree := NewSubTree(name, parentFullKey)
+	for _, childName := range fn.order {
+		child, err := fn.children[childName].build(childName, fullKey)
+		if err != nil {
+			return nil, err
+		}
+		subTree.AddOrReplaceDirectly(childName, child)
+	}
+
+	return subTree, nil
+}
+
+// isBranch reports whether all children names are sequential indexes starting from zero.
+func (fn *flatNode) isBranch() bool {
+	for i := 0; i < len(fn.children); i++ {
+		if _, ok := fn.children[strconv.Itoa(i)]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// walkLeaves calls the function for every leaf with path of names from the root, order of trees is preserved.
+func walkLeaves(m Marshalable, path Path, fn func(Path, *Leaf) error) error {
+	switch item := m.(type) {
+	case *Leaf:
+		return fn(path, item)
+	case *Tree:
+		for _, name := range item.Order {
+			if err := walkLeaves(item.Content[name], append(path[:len(path):len(path)], name), fn); err != nil {
+				return err
+			}
+		}
+	case *Branch:
+		for i, child := range item.Content {
+			if err := walkLeaves(child, append(path[:len(path):len(path)], strconv.Itoa(i)), fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,448 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HCL expressions which can't be evaluated without a context (references, functions, templates)
+// are kept in leaves as their source code with this tag and they're written back as is.
+const hclExpressionTag = "!hcl"
+
+var hclIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// UnmarshalHCL fills the tree from HCL v2 native syntax document. Blocks become sub-trees nested by their labels,
+// repeated blocks with the same type and labels become branches of trees, lists become branches.
+func (mt *Tree) UnmarshalHCL(raw []byte) error {
+	mt.clearValues()
+
+	file, diags := hclsyntax.ParseConfig(raw, "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return fmt.Errorf("parse HCL: %w", diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return fmt.Errorf("unexpected HCL body type %T", file.Body)
+	}
+
+	return mt.fillFromHCL(body, raw)
+}
+
+// MarshalHCL writes the tree as HCL document following the order of keys. Sub-trees are written as blocks,
+// with labels if they were unmarshaled from labeled blocks, branches of trees as repeated blocks and other branches as lists.
+func (mt *Tree) MarshalHCL(indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeHCLBody(&buf, mt, indent, 0); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mt *Tree) fillFromHCL(body *hclsyntax.Body, raw []byte) error {
+	type item struct {
+		start     int
+		attribute *hclsyntax.Attribute
+		block     *hclsyntax.Block
+	}
+
+	items := make([]item, 0, len(body.Attributes)+len(body.Blocks))
+	for _, attribute := range body.Attributes {
+		items = append(items, item{start: attribute.SrcRange.Start.Byte, attribute: attribute})
+	}
+	for _, block := range body.Blocks {
+		items = append(items, item{start: block.TypeRange.Start.Byte, block: block})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].start < items[j].start
+	})
+
+	for _, it := range items {
+		if it.attribute != nil {
+			child, err := hclExpressionToMarshalable(it.attribute.Expr, raw, it.attribute.Name, mt.FullKey)
+			if err != nil {
+				return fmt.Errorf("unmarshal attribute %q: %w", it.attribute.Name, err)
+			}
+			mt.AddOrReplaceDirectly(it.attribute.Name, child)
+			continue
+		}
+
+		if err := mt.addHCLBlock(it.block, raw); err != nil {
+			return fmt.Errorf("unmarshal block %q: %w", it.block.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// addHCLBlock adds the block body as a sub-tree by path of its type and labels.
+func (mt *Tree) addHCLBlock(block *hclsyntax.Block, raw []byte) error {
+	path := append([]string{block.Type}, block.Labels...)
+
+	parent := mt
+	for _, name := range path[:len(path)-1] {
+		switch existing := parent.Content[name].(type) {
+		case nil:
+			subTree := NewSubTree(name, parent.FullKey)
+			subTree.hclLabeled = true
+			parent.AddOrReplaceDirectly(name, subTree)
+			parent = subTree
+		case *Tree:
+			parent = existing
+		default:
+			return fmt.Errorf("%q is already defined as not a block", MakeFullKey(parent.FullKey, name))
+		}
+	}
+
+	name := path[len(path)-1]
+	bodyTree := NewSubTree(name, parent.FullKey)
+	if err := bodyTree.fillFromHCL(block.Body, raw); err != nil {
+		return err
+	}
+
+	switch existing := parent.Content[name].(type) {
+	case nil:
+		parent.AddOrReplaceDirectly(name, bodyTree)
+	case *Tree:
+		// the second block with the same path turns the sub-tree to branch
+		branch := NewBranch(name, parent.FullKey)
+		branch.Add(existing)
+		branch.Add(bodyTree)
+		parent.AddOrReplaceDirectly(name, branch)
+	case *Branch:
+		existing.AddOrReplaceDirectly(len(existing.Content), bodyTree)
+	default:
+		return fmt.Errorf("%q is already defined as not a block", MakeFullKey(parent.FullKey, name))
+	}
+
+	return nil
+}
+
+func hclExpressionToMarshalable(expr hclsyntax.Expression, raw []byte, name, parentFullKey string) (Marshalable, error) {
+	switch e := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		subTree := NewSubTree(name, parentFullKey)
+		for _, item := range e.Items {
+			keyValue, diags := item.KeyExpr.Value(nil)
+			if diags.HasErrors() || keyValue.IsNull() || !keyValue.Type().Equals(cty.String) {
+				return nil, fmt.Errorf("object key %q must be a string", item.KeyExpr.Range().SliceBytes(raw))
+			}
+			key := keyValue.AsString()
+			child, err := hclExpressionToMarshalable(item.ValueExpr, raw, key, subTree.FullKey)
+			if err != nil {
+				return nil, fmt.Errorf("unmarshal %q: %w", key, err)
+			}
+			subTree.AddOrReplaceDirectly(key, child)
+		}
+		return subTree, nil
+	case *hclsyntax.TupleConsExpr:
+		branch := NewBranch(name, parentFullKey)
+		for i, itemExpr := range e.Exprs {
+			child, err := hclExpressionToMarshalable(itemExpr, raw, strconv.Itoa(i), branch.FullKey)
+			if err != nil {
+				return nil, fmt.Errorf("unmarshal #%d: %w", i, err)
+			}
+			branch.Add(child)
+		}
+		return branch, nil
+	}
+
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = string(expr.Range().SliceBytes(raw))
+		leaf.Tag = hclExpressionTag
+		return leaf, nil
+	}
+
+	return ctyToMarshalable(value, name, parentFullKey)
+}
+
+func ctyToMarshalable(value cty.Value, name, parentFullKey string) (Marshalable, error) {
+	if value.IsNull() {
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.SetValue(nil)
+		return leaf, nil
+	}
+
+	valueType := value.Type()
+	switch {
+	case valueType == cty.String:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = value.AsString()
+		return leaf, nil
+	case valueType == cty.Bool:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = value.True()
+		return leaf, nil
+	case valueType == cty.Number:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = hclNumber(value.AsBigFloat())
+		return leaf, nil
+	case valueType.IsTupleType() || valueType.IsListType() || valueType.IsSetType():
+		branch := NewBranch(name, parentFullKey)
+		for i, element := range value.AsValueSlice() {
+			child, err := ctyToMarshalable(element, strconv.Itoa(i), branch.FullKey)
+			if err != nil {
+				return nil, err
+			}
+			branch.Add(child)
+		}
+		return branch, nil
+	case valueType.IsObjectType() || valueType.IsMapType():
+		subTree := NewSubTree(name, parentFullKey)
+		valueMap := value.AsValueMap()
+		keys := make([]string, 0, len(valueMap))
+		for k := range valueMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child, err := ctyToMarshalable(valueMap[k], k, subTree.FullKey)
+			if err != nil {
+				return nil, err
+			}
+			subTree.AddOrReplaceDirectly(k, child)
+		}
+		return subTree, nil
+	default:
+		return nil, fmt.Errorf("unsupported HCL value type %s", valueType.FriendlyName())
+	}
+}
+
+func hclNumber(f *big.Float) interface{} {
+	if f.IsInt() {
+		if i, accuracy := f.Int64(); accuracy == big.Exact {
+			return i
+		}
+	}
+	value, _ := f.Float64()
+
+	return value
+}
+
+func writeHCLBody(buf *bytes.Buffer, mt *Tree, indent, level int) error {
+	prefix := strings.Repeat(" ", indent*level)
+	for i, name := range mt.Order {
+		if !hclIdentifierRegexp.MatchString(name) {
+			return fmt.Errorf("name %q can't be written as HCL attribute or block", name)
+		}
+
+		switch item := mt.Content[name].(type) {
+		case *Tree:
+			if !isHCLBlock(item) {
+				break
+			}
+			if i > 0 {
+				buf.WriteRune('\n')
+			}
+			if err := writeHCLBlock(buf, item, name, indent, level); err != nil {
+				return err
+			}
+			continue
+		case *Branch:
+			if !isHCLBlocks(item) {
+				break
+			}
+			if i > 0 {
+				buf.WriteRune('\n')
+			}
+			for j, element := range item.Content {
+				if j > 0 {
+					buf.WriteRune('\n')
+				}
+				if err := writeHCLBlock(buf, element.(*Tree), name, indent, level); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		buf.WriteString(prefix + name + " = ")
+		if err := writeHCLValue(buf, mt.Content[name], indent, level); err != nil {
+			return fmt.Errorf("marshal %q: %w", name, err)
+		}
+		buf.WriteRune('\n')
+	}
+
+	return nil
+}
+
+func writeHCLBlock(buf *bytes.Buffer, mt *Tree, name string, indent, level int) error {
+	if mt.hasHCLLabels() {
+		return writeHCLLabeledBlocks(buf, mt, name, indent, level)
+	}
+
+	prefix := strings.Repeat(" ", indent*level)
+	buf.WriteString(prefix + name + " {\n")
+	if err := writeHCLBody(buf, mt, indent, level+1); err != nil {
+		return fmt.Errorf("marshal block %q: %w", name, err)
+	}
+	buf.WriteString(prefix + "}\n")
+
+	return nil
+}
+
+// writeHCLLabeledBlocks writes blocks of the tree's children with their names as the next labels.
+func writeHCLLabeledBlocks(buf *bytes.Buffer, mt *Tree, header string, indent, level int) error {
+	for i, label := range mt.Order {
+		if i > 0 {
+			buf.WriteRune('\n')
+		}
+		var labelBuf bytes.Buffer
+		writeHCLString(&labelBuf, label)
+		labeledHeader := header + " " + labelBuf.String()
+
+		switch item := mt.Content[label].(type) {
+		case *Tree:
+			if err := writeHCLBlock(buf, item, labeledHeader, indent, level); err != nil {
+				return err
+			}
+		case *Branch:
+			for j, element := range item.Content {
+				if j > 0 {
+					buf.WriteRune('\n')
+				}
+				if err := writeHCLBlock(buf, element.(*Tree), labeledHeader, indent, level); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeHCLValue writes expressions: literals, tuples and objects.
+func writeHCLValue(buf *bytes.Buffer, m Marshalable, indent, level int) error {
+	switch item := m.(type) {
+	case *Tree:
+		if item.IsEmpty() {
+			buf.WriteString("{}")
+			return nil
+		}
+		prefix := strings.Repeat(" ", indent*(level+1))
+		buf.WriteString("{\n")
+		for _, name := range item.Order {
+			key := name
+			if !hclIdentifierRegexp.MatchString(name) {
+				var keyBuf bytes.Buffer
+				writeHCLString(&keyBuf, name)
+				key = keyBuf.String()
+			}
+			buf.WriteString(prefix + key + " = ")
+			if err := writeHCLValue(buf, item.Content[name], indent, level+1); err != nil {
+				return fmt.Errorf("marshal %q: %w", name, err)
+			}
+			buf.WriteRune('\n')
+		}
+		buf.WriteString(strings.Repeat(" ", indent*level) + "}")
+	case *Branch:
+		buf.WriteRune('[')
+		for i, child := range item.Content {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeHCLValue(buf, child, indent, level); err != nil {
+				return fmt.Errorf("marshal #%d: %w", i, err)
+			}
+		}
+		buf.WriteRune(']')
+	case *Leaf:
+		return writeHCLScalar(buf, item)
+	default:
+		return fmt.Errorf("unsupported type %T", m)
+	}
+
+	return nil
+}
+
+func writeHCLScalar(buf *bytes.Buffer, leaf *Leaf) error {
+	switch v := leaf.Value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		if leaf.Tag == hclExpressionTag {
+			buf.WriteString(v)
+		} else {
+			writeHCLString(buf, v)
+		}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("unsupported float value %v", v)
+		}
+		buf.WriteString(FormatScalar(v))
+	default:
+		buf.WriteString(FormatScalar(normalizeScalar(v)))
+	}
+
+	return nil
+}
+
+// isHCLBlock reports whether the tree can be written as a block: names of attributes and blocks must be identifiers,
+// children of labeled blocks must be blocks.
+func isHCLBlock(mt *Tree) bool {
+	if mt.hasHCLLabels() {
+		for _, name := range mt.Order {
+			switch item := mt.Content[name].(type) {
+			case *Tree:
+				if !isHCLBlock(item) {
+					return false
+				}
+			case *Branch:
+				if !isHCLBlocks(item) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, name := range mt.Order {
+		if !hclIdentifierRegexp.MatchString(name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasHCLLabels reports whether the tree is a type or a label of HCL blocks and its children are the next labels.
+func (mt *Tree) hasHCLLabels() bool {
+	return mt.hclLabeled && len(mt.Order) > 0
+}
+
+func isHCLBlocks(mb *Branch) bool {
+	if len(mb.Content) == 0 {
+		return false
+	}
+	for _, element := range mb.Content {
+		if subTree, ok := element.(*Tree); !ok || !isHCLBlock(subTree) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var (
+	hclEscapes = stringEscapes{runes: quotedEscapes, unicode: true}
+	// template sequences must be escaped to be read as literals
+	hclTemplateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+)
+
+func writeHCLString(buf *bytes.Buffer, s string) {
+	buf.WriteString(hclEscapes.quote(hclTemplateEscaper.Replace(s)))
+}
@@ -16,6 +16,39 @@ func MakeFullKey(prefix string, key string) string {
 	return key
 }
 
+const (
+	escapeChar = "%"
+	escapedSep = escapeChar + "2F"
+	escapedEsc = escapeChar + "25"
+)
+
+var (
+	keyNameEscaper   = strings.NewReplacer(escapeChar, escapedEsc, sep, escapedSep)
+	keyNameUnescaper = strings.NewReplacer(escapedEsc, escapeChar, escapedSep, sep)
+)
+
+// EscapeKeyName escapes the separator in the name, so the name can be used as a part of full key.
+// "/" is written as "%2F" and "%" as "%25".
+func EscapeKeyName(name string) string {
+	return keyNameEscaper.Replace(name)
+}
+
+// UnescapeKeyName returns the name from the escaped part of full key. Only "%2F" and "%25" are unescaped,
+// other percent signs are kept as is.
+func UnescapeKeyName(part string) string {
+	return keyNameUnescaper.Replace(part)
+}
+
+// SplitFullKey splits the full key to unescaped names.
+func SplitFullKey(fullKey string) Path {
+	path := strings.Split(fullKey, sep)
+	for i := range path {
+		path[i] = UnescapeKeyName(path[i])
+	}
+
+	return path
+}
+
 var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
 var matchAllCap = regexp.MustCompile("([a-z0-9])([A-Z])")
 var matchAllSpecSymbols = regexp.MustCompile("[^A-z0-9]")
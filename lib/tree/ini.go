@@ -0,0 +1,194 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	iniSectionSep  = "."
+	iniArraySuffix = "[]"
+)
+
+// UnmarshalINI fills the tree from INI document. Dotted section headers become nested sub-trees,
+// keys with "[]" suffix are collected to branches. Keys of lines are not split, all values are kept as strings.
+func (mt *Tree) UnmarshalINI(raw []byte) error {
+	mt.clearValues()
+
+	var (
+		root    = newFlatNode()
+		section Path
+		counts  = make(map[string]int)
+	)
+	lines := strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return fmt.Errorf("line %d: unclosed section header", i+1)
+			}
+			var err error
+			if section, err = splitFlatKey(strings.TrimSpace(line[1:end]), iniSectionSep); err != nil {
+				return fmt.Errorf("line %d: section: %w", i+1, err)
+			}
+			for j := range section {
+				section[j] = strings.TrimSpace(section[j])
+			}
+			root.addNode(section)
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: missing '=' after key", i+1)
+		}
+		key := strings.TrimSpace(line[:eq])
+		value, err := unquoteINI(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		path := append(section[:len(section):len(section)], strings.TrimSuffix(key, iniArraySuffix))
+		if strings.HasSuffix(key, iniArraySuffix) {
+			fullKey := strings.Join(path, sep)
+			path = append(path, strconv.Itoa(counts[fullKey]))
+			counts[fullKey]++
+		}
+		if path[len(path)-1] == "" {
+			return fmt.Errorf("line %d: empty key", i+1)
+		}
+		root.add(path, value)
+	}
+
+	return root.fill(mt)
+}
+
+// MarshalINI writes the tree as INI document. Leaves of the root are written before sections,
+// sub-trees become sections with dotted headers and branches of leaves become keys with "[]" suffix.
+func (mt *Tree) MarshalINI() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeINISection(&buf, mt, nil); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeINISection(buf *bytes.Buffer, mt *Tree, path []string) error {
+	hasValues := false
+	for _, item := range mt.Content {
+		if _, ok := item.(*Tree); !ok {
+			hasValues = true
+			break
+		}
+	}
+	// headers of sections which contain only sub-sections are redundant
+	if len(path) > 0 && (hasValues || mt.IsEmpty()) {
+		if buf.Len() > 0 {
+			buf.WriteRune('\n')
+		}
+		buf.WriteString("[" + strings.Join(path, iniSectionSep) + "]\n")
+	}
+
+	var sections []string
+	for _, name := range mt.Order {
+		if err := checkINIKey(name); err != nil {
+			return err
+		}
+		switch item := mt.Content[name].(type) {
+		case *Tree:
+			if strings.Contains(name, iniSectionSep) || strings.ContainsAny(name, "[]") {
+				return fmt.Errorf("section name %q contains reserved characters", name)
+			}
+			sections = append(sections, name)
+		case *Branch:
+			for i, element := range item.Content {
+				leaf, ok := element.(*Leaf)
+				if !ok {
+					return fmt.Errorf("marshal %q: element #%d: only leaves are supported in arrays, got %T", name, i, element)
+				}
+				buf.WriteString(name + iniArraySuffix + " = " + quoteINI(flatValue(leaf)) + "\n")
+			}
+		case *Leaf:
+			buf.WriteString(name + " = " + quoteINI(flatValue(item)) + "\n")
+		default:
+			return fmt.Errorf("marshal %q: unsupported type %T", name, item)
+		}
+	}
+
+	for _, name := range sections {
+		childPath := append(append([]string{}, path...), name)
+		if err := writeINISection(buf, mt.Content[name].(*Tree), childPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkINIKey(name string) error {
+	if name == "" || name != strings.TrimSpace(name) || strings.ContainsAny(name, "=\n\r") ||
+		strings.IndexAny(name[:1], "[;#") == 0 || strings.HasSuffix(name, iniArraySuffix) {
+		return fmt.Errorf("key %q can't be written to INI", name)
+	}
+
+	return nil
+}
+
+// unquoteINI returns content of the double quoted value or the bare value without inline comment.
+func unquoteINI(value string) (string, error) {
+	if value == "" || value[0] != '"' {
+		for _, marker := range []string{" ;", " #", "\t;", "\t#"} {
+			if comment := strings.Index(value, marker); comment >= 0 {
+				value = value[:comment]
+			}
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	var buf strings.Builder
+	i := 1
+	for ; i < len(value) && value[i] != '"'; i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			buf.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		default:
+			buf.WriteByte(value[i])
+		}
+	}
+	if i == len(value) {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	if tail := strings.TrimSpace(value[i+1:]); tail != "" && tail[0] != ';' && tail[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after quoted value", tail)
+	}
+
+	return buf.String(), nil
+}
+
+// quoteINI writes values which are read back unchanged as is and others in double quotes with escapes.
+func quoteINI(value string) string {
+	if value == strings.TrimSpace(value) && !strings.ContainsAny(value, "\";#\n\r\t") {
+		return value
+	}
+
+	return iniEscapes.quote(value)
+}
+
+var iniEscapes = stringEscapes{runes: quotedEscapes}
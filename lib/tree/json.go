@@ -54,11 +54,20 @@ func (mb *Branch) MarshalJSON() ([]byte, error) {
 }
 
 func (ml *Leaf) MarshalJSON() ([]byte, error) {
+	if literal, ok := ml.bigIntLiteral(); ok {
+		return []byte(literal), nil
+	}
+
 	encodedValue, err := json.Marshal(ml.Value)
 	if err != nil {
 		return nil, fmt.Errorf("marshal leaf value %q: %w", ml.Value, err)
 	}
 
+	// without a point or an exponent the float becomes an integer after the next unmarshal
+	if _, isFloat := ml.Value.(float64); isFloat && !bytes.ContainsAny(encodedValue, ".eE") {
+		encodedValue = append(encodedValue, ".0"...)
+	}
+
 	return encodedValue, nil
 }
 
@@ -109,8 +118,8 @@ func (ml *Leaf) UnmarshalJSON(raw []byte) error {
 	}
 
 	ml.Name = name
-	ml.Value = value
-	ml.FullKey = MakeFullKey("", name)
+	ml.SetValue(value)
+	ml.FullKey = ml.naming.makeFullKey("", name)
 
 	return nil
 }
@@ -120,6 +129,7 @@ func (mb *Branch) UnmarshalJSON(raw []byte) error {
 	if mb.decoder == nil {
 		raw = bytes.TrimSpace(raw)
 		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
 
 		token, err := dec.Token()
 		if err != nil {
@@ -149,7 +159,7 @@ func (mb *Branch) UnmarshalJSON(raw []byte) error {
 		if !ok {
 			leaf := NewLeaf(name, mb.FullKey)
 			leaf.decoder = mb.decoder
-			leaf.Value = token
+			leaf.setJSONValue(token)
 			child = leaf
 		} else {
 			switch delim {
@@ -184,11 +194,15 @@ func (mb *Branch) UnmarshalJSON(raw []byte) error {
 	return nil
 }
 
+// UnmarshalJSON fills the tree from JSON object. Order of keys is preserved,
+// sibling keys which are converted to the same full keys are reported by *CollisionError.
 func (mt *Tree) UnmarshalJSON(raw []byte) error {
 	mt.clearValues()
-	if mt.decoder == nil {
+	isRoot := mt.decoder == nil
+	if isRoot {
 		raw = bytes.TrimSpace(raw)
 		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
 
 		token, err := dec.Token()
 		if err != nil {
@@ -213,6 +227,7 @@ func (mt *Tree) UnmarshalJSON(raw []byte) error {
 		if !ok {
 			return fmt.Errorf("name must be a string, got: %T", keyToken)
 		}
+		pos := Position{Offset: mt.decoder.InputOffset()}
 
 		var child Marshalable
 
@@ -225,17 +240,20 @@ func (mt *Tree) UnmarshalJSON(raw []byte) error {
 		if !ok {
 			leaf := NewLeaf(name, mt.FullKey)
 			leaf.decoder = mt.decoder
-			leaf.Value = token
+			leaf.pos = pos
+			leaf.setJSONValue(token)
 			child = leaf
 		} else {
 			switch delim {
 			case '{':
 				childTree := NewSubTree(name, mt.FullKey)
 				childTree.decoder = mt.decoder
+				childTree.pos = pos
 				child = childTree
 			case '[':
 				branch := NewBranch(name, mt.FullKey)
 				branch.decoder = mt.decoder
+				branch.pos = pos
 				child = branch
 			default:
 				return fmt.Errorf("got unpredictable token '%v'", token)
@@ -257,6 +275,19 @@ func (mt *Tree) UnmarshalJSON(raw []byte) error {
 	if delim, ok := token.(json.Delim); !ok || delim != '}' {
 		return fmt.Errorf("expect JSON object close with '}'")
 	}
+	if isRoot {
+		return mt.CheckKeyCollisions(nil)
+	}
 
 	return nil
 }
+
+// setJSONValue sets the value of JSON token. Integers which don't fit int64 are kept as literals with the integer tag.
+func (ml *Leaf) setJSONValue(token json.Token) {
+	ml.SetValue(normalizeScalar(token))
+	if number, ok := token.(json.Number); ok && isIntegerLiteral(number.String()) {
+		if _, isString := ml.Value.(string); isString {
+			ml.Tag = intTag
+		}
+	}
+}
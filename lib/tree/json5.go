@@ -0,0 +1,457 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	json5IdentifierRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	json5NumberRegexp     = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+	json5HexRegexp        = regexp.MustCompile(`^[+-]?0[xX][0-9A-Fa-f]+$`)
+)
+
+type json5Parser struct {
+	raw []byte
+	pos int
+}
+
+// UnmarshalJSON5 fills the tree from JSON5 document, which covers JSON with comments too. Order of keys is preserved.
+// Comments, trailing commas, unquoted keys, single quoted strings, hexadecimal numbers, infinities and NaN are allowed.
+func (mt *Tree) UnmarshalJSON5(raw []byte) error {
+	mt.clearValues()
+	p := &json5Parser{raw: raw}
+
+	if err := p.skipSpaces(); err != nil {
+		return err
+	}
+	if !p.consume('{') {
+		return p.errorf("JSON5 document must be an object")
+	}
+	if err := p.parseObject(mt); err != nil {
+		return err
+	}
+
+	if err := p.skipSpaces(); err != nil {
+		return err
+	}
+	if p.pos < len(p.raw) {
+		return p.errorf("unexpected content after the root object")
+	}
+
+	return nil
+}
+
+func (p *json5Parser) parseObject(mt *Tree) error {
+	for {
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+		if p.consume('}') {
+			return nil
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+		if !p.consume(':') {
+			return p.errorf("expected ':' after key %q", key)
+		}
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+
+		child, err := p.parseValue(key, mt.FullKey)
+		if err != nil {
+			return fmt.Errorf("unmarshal %q: %w", key, err)
+		}
+		mt.AddOrReplaceDirectly(key, child)
+
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+		if !p.consume(',') && !p.peek('}') {
+			return p.errorf("expected ',' or '}' after value of %q", key)
+		}
+	}
+}
+
+func (p *json5Parser) parseArray(mb *Branch) error {
+	for i := 0; ; i++ {
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+		if p.consume(']') {
+			return nil
+		}
+
+		child, err := p.parseValue(strconv.Itoa(i), mb.FullKey)
+		if err != nil {
+			return fmt.Errorf("unmarshal #%d: %w", i, err)
+		}
+		mb.Add(child)
+
+		if err := p.skipSpaces(); err != nil {
+			return err
+		}
+		if !p.consume(',') && !p.peek(']') {
+			return p.errorf("expected ',' or ']' after element #%d", i)
+		}
+	}
+}
+
+func (p *json5Parser) parseKey() (string, error) {
+	if p.peek('"') || p.peek('\'') {
+		return p.parseString()
+	}
+
+	token := p.readToken()
+	if !json5IdentifierRegexp.MatchString(token) {
+		return "", p.errorf("incorrect key %q", token)
+	}
+
+	return token, nil
+}
+
+func (p *json5Parser) parseValue(name, parentFullKey string) (Marshalable, error) {
+	if p.pos >= len(p.raw) {
+		return nil, p.errorf("unexpected end of JSON5")
+	}
+
+	switch p.raw[p.pos] {
+	case '{':
+		p.pos++
+		subTree := NewSubTree(name, parentFullKey)
+		return subTree, p.parseObject(subTree)
+	case '[':
+		p.pos++
+		branch := NewBranch(name, parentFullKey)
+		return branch, p.parseArray(branch)
+	case '"', '\'':
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = value
+		return leaf, nil
+	}
+
+	token := p.readToken()
+	value, err := p.parseScalar(token)
+	if err != nil {
+		return nil, err
+	}
+	leaf := NewLeaf(name, parentFullKey)
+	leaf.SetValue(value)
+	// integers which don't fit int64 are kept as strings, the tag keeps their type
+	if _, isString := value.(string); isString && isIntegerLiteral(token) {
+		leaf.Tag = intTag
+	}
+
+	return leaf, nil
+}
+
+func (p *json5Parser) parseScalar(token string) (interface{}, error) {
+	switch token {
+	case "":
+		return nil, p.errorf("unexpected '%c'", p.raw[p.pos])
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "Infinity", "+Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	case "NaN", "+NaN", "-NaN":
+		return math.NaN(), nil
+	}
+
+	switch {
+	case json5HexRegexp.MatchString(token):
+		value, err := strconv.ParseInt(token, 0, 64)
+		if err != nil {
+			return nil, p.errorf("incorrect number %q: %v", token, err)
+		}
+		return value, nil
+	case json5NumberRegexp.MatchString(token):
+		number := strings.TrimPrefix(token, "+")
+		// leading and trailing points aren't allowed in JSON numbers
+		number = strings.Replace(number, "-.", "-0.", 1)
+		if strings.HasPrefix(number, ".") {
+			number = "0" + number
+		}
+		number = strings.Replace(number, ".e", ".0e", 1)
+		number = strings.Replace(number, ".E", ".0E", 1)
+		if strings.HasSuffix(number, ".") {
+			number += "0"
+		}
+		return normalizeScalar(json.Number(number)), nil
+	default:
+		return nil, p.errorf("unexpected token %q", token)
+	}
+}
+
+func (p *json5Parser) parseString() (string, error) {
+	quote := p.raw[p.pos]
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.raw) {
+		c := p.raw[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\n', '\r':
+			return "", p.errorf("unescaped line break in string")
+		case '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *json5Parser) parseEscape(sb *strings.Builder) error {
+	if p.pos >= len(p.raw) {
+		return p.errorf("unterminated string")
+	}
+	escaped := p.raw[p.pos]
+	p.pos++
+
+	switch escaped {
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'v':
+		sb.WriteByte('\v')
+	case '0':
+		sb.WriteByte(0)
+	case '\r':
+		// escaped line break continues the string
+		p.consume('\n')
+	case '\n':
+	case 'x':
+		code, err := p.readHex(2)
+		if err != nil {
+			return err
+		}
+		sb.WriteRune(rune(code))
+	case 'u':
+		code, err := p.readHex(4)
+		if err != nil {
+			return err
+		}
+		r := rune(code)
+		if utf16.IsSurrogate(r) && p.pos+6 <= len(p.raw) && p.raw[p.pos] == '\\' && p.raw[p.pos+1] == 'u' {
+			p.pos += 2
+			low, err := p.readHex(4)
+			if err != nil {
+				return err
+			}
+			r = utf16.DecodeRune(r, rune(low))
+		}
+		sb.WriteRune(r)
+	default:
+		// other escaped characters are written as is, including multibyte ones
+		p.pos--
+		r, size := utf8.DecodeRune(p.raw[p.pos:])
+		p.pos += size
+		if r != '\u2028' && r != '\u2029' {
+			sb.WriteRune(r)
+		}
+	}
+
+	return nil
+}
+
+func (p *json5Parser) readHex(digits int) (uint64, error) {
+	if p.pos+digits > len(p.raw) {
+		return 0, p.errorf("incorrect hexadecimal escape")
+	}
+	code, err := strconv.ParseUint(string(p.raw[p.pos:p.pos+digits]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("incorrect hexadecimal escape")
+	}
+	p.pos += digits
+
+	return code, nil
+}
+
+// readToken reads literals and numbers till the delimiter.
+func (p *json5Parser) readToken() string {
+	start := p.pos
+	for p.pos < len(p.raw) && !isJSON5Delimiter(p.raw[p.pos]) {
+		p.pos++
+	}
+
+	return string(p.raw[start:p.pos])
+}
+
+// skipSpaces skips whitespaces and comments.
+func (p *json5Parser) skipSpaces() error {
+	for p.pos < len(p.raw) {
+		switch {
+		case isJSON5Space(p.raw[p.pos]):
+			p.pos++
+		case p.peekString("\u00a0"), p.peekString("\ufeff"), p.peekString("\u2028"), p.peekString("\u2029"):
+			_, size := utf8.DecodeRune(p.raw[p.pos:])
+			p.pos += size
+		case p.peekString("//"):
+			for p.pos < len(p.raw) && p.raw[p.pos] != '\n' {
+				p.pos++
+			}
+		case p.peekString("/*"):
+			end := bytes.Index(p.raw[p.pos+2:], []byte("*/"))
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *json5Parser) consume(c byte) bool {
+	if p.peek(c) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *json5Parser) peek(c byte) bool {
+	return p.pos < len(p.raw) && p.raw[p.pos] == c
+}
+
+func (p *json5Parser) peekString(s string) bool {
+	return bytes.HasPrefix(p.raw[p.pos:], []byte(s))
+}
+
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	line := 1 + bytes.Count(p.raw[:p.pos], []byte("\n"))
+	return fmt.Errorf("JSON5 line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func isJSON5Space(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	default:
+		return false
+	}
+}
+
+func isJSON5Delimiter(c byte) bool {
+	return isJSON5Space(c) || strings.IndexByte(`,:[]{}"'/`, c) >= 0
+}
+
+// MarshalJSON5 writes the tree as JSON, but infinities and NaN are written as JSON5 literals
+// instead of failing. If indent is zero, the document is written in one line.
+func (mt *Tree) MarshalJSON5(indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON5(&buf, mt, indent, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteRune('\n')
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON5(buf *bytes.Buffer, m Marshalable, indent, level int) error {
+	switch item := m.(type) {
+	case *Tree:
+		buf.WriteRune('{')
+		for i, name := range item.Order {
+			writeJSON5Separator(buf, indent, level+1, i == 0)
+			encodedName, err := json.Marshal(name)
+			if err != nil {
+				return fmt.Errorf("marshal name %q: %w", name, err)
+			}
+			buf.Write(encodedName)
+			buf.WriteRune(':')
+			if indent > 0 {
+				buf.WriteRune(' ')
+			}
+			if err := writeJSON5(buf, item.Content[name], indent, level+1); err != nil {
+				return fmt.Errorf("marshal %q: %w", name, err)
+			}
+		}
+		if indent > 0 && len(item.Order) > 0 {
+			buf.WriteString("\n" + strings.Repeat(" ", indent*level))
+		}
+		buf.WriteRune('}')
+	case *Branch:
+		buf.WriteRune('[')
+		for i, element := range item.Content {
+			writeJSON5Separator(buf, indent, level+1, i == 0)
+			if err := writeJSON5(buf, element, indent, level+1); err != nil {
+				return fmt.Errorf("marshal #%d: %w", i, err)
+			}
+		}
+		if indent > 0 && len(item.Content) > 0 {
+			buf.WriteString("\n" + strings.Repeat(" ", indent*level))
+		}
+		buf.WriteRune(']')
+	case *Leaf:
+		if v, ok := item.Value.(float64); ok && (math.IsInf(v, 0) || math.IsNaN(v)) {
+			switch {
+			case math.IsNaN(v):
+				buf.WriteString("NaN")
+			case v > 0:
+				buf.WriteString("Infinity")
+			default:
+				buf.WriteString("-Infinity")
+			}
+			return nil
+		}
+		encodedValue, err := item.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf.Write(encodedValue)
+	default:
+		return fmt.Errorf("unsupported type %T", m)
+	}
+
+	return nil
+}
+
+func writeJSON5Separator(buf *bytes.Buffer, indent, level int, isFirst bool) {
+	if !isFirst {
+		buf.WriteRune(',')
+	}
+	if indent > 0 {
+		buf.WriteString("\n" + strings.Repeat(" ", indent*level))
+	}
+}
@@ -0,0 +1,180 @@
+package tree
+
+import "fmt"
+
+// BranchStrategy defines how a branch of the overlay tree is merged into the branch of the base tree.
+type BranchStrategy string
+
+const (
+	// BranchReplace replaces the base branch by the overlay one, it's used by default.
+	BranchReplace BranchStrategy = "replace"
+	// BranchAppend appends elements of the overlay branch to the base branch.
+	BranchAppend BranchStrategy = "append"
+	// BranchMergeByKey merges sub-trees of branches which have the same value of the key field,
+	// other elements of the overlay branch are appended.
+	BranchMergeByKey BranchStrategy = "key"
+)
+
+// MergeOptions configure merging of branches by Merge.
+type MergeOptions struct {
+	// Branches is the strategy of branches which aren't listed in BranchKeyFields.
+	Branches BranchStrategy
+	// KeyField is the field which identifies elements of branches merged by BranchMergeByKey strategy.
+	KeyField string
+	// BranchKeyFields are key fields of branches by their full keys, these branches are always merged by key.
+	BranchKeyFields map[string]string
+}
+
+// Merge returns deep merge of the overlay tree into the base tree, both trees stay unchanged.
+// Sub-trees are merged recursively, leaves and nodes of different types are replaced by the overlay ones,
+// branches are merged by the strategy of options. Names of the result have the key naming of the base tree,
+// nodes are matched by full keys, so names "maxConns" and "max_conns" override each other under the snake naming.
+func Merge(base, overlay *Tree, opts MergeOptions) (*Tree, error) {
+	switch opts.Branches {
+	case "", BranchReplace, BranchAppend, BranchMergeByKey:
+	default:
+		return nil, fmt.Errorf("unknown branch strategy %q", opts.Branches)
+	}
+
+	merged := base.DeepClone()
+	if err := merged.mergeTree(overlay.DeepClone(), opts); err != nil {
+		return nil, err
+	}
+	if err := merged.CheckKeyCollisions(nil); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// merge deeply merges the source tree into the tree: sub-trees are merged recursively, other nodes are replaced.
+// Nodes of the source tree are moved, so it can't be used after that.
+func (mt *Tree) merge(src *Tree) {
+	// replacing of branches never fails
+	_ = mt.mergeTree(src, MergeOptions{})
+}
+
+func (mt *Tree) mergeTree(src *Tree, opts MergeOptions) error {
+	names := mt.namesByFullKey()
+	for _, srcName := range src.Order {
+		// nodes are matched by full keys, so names of different styles override each other and keep the base name
+		fullKey := mt.naming.makeNodeFullKey(mt.FullKey, srcName, src.Content[srcName])
+		name, ok := names[fullKey]
+		if !ok {
+			name = srcName
+			names[fullKey] = name
+		}
+
+		switch srcItem := src.Content[srcName].(type) {
+		case *Tree:
+			if dstTree, ok := mt.Content[name].(*Tree); ok {
+				if err := dstTree.mergeTree(srcItem, opts); err != nil {
+					return err
+				}
+				continue
+			}
+		case *Branch:
+			dstBranch, ok := mt.Content[name].(*Branch)
+			if !ok {
+				break
+			}
+			strategy, keyField := opts.branchStrategy(dstBranch.FullKey)
+			switch strategy {
+			case BranchAppend:
+				for _, element := range srcItem.Content {
+					dstBranch.AddOrReplaceDirectly(len(dstBranch.Content), element)
+				}
+				continue
+			case BranchMergeByKey:
+				if err := dstBranch.mergeByKey(srcItem, keyField, opts); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		mt.AddOrReplaceDirectly(name, src.Content[srcName])
+	}
+
+	return nil
+}
+
+// namesByFullKey returns names of the tree children by their full keys.
+func (mt *Tree) namesByFullKey() map[string]string {
+	names := make(map[string]string, len(mt.Order))
+	for _, name := range mt.Order {
+		names[mt.Content[name].GetFullKey()] = name
+	}
+
+	return names
+}
+
+// childName returns the name of the child which has the same full key as the name, or the name itself.
+func (mt *Tree) childName(name string) string {
+	fullKey := mt.naming.makeFullKey(mt.FullKey, name)
+	for _, childName := range mt.Order {
+		if mt.Content[childName].GetFullKey() == fullKey {
+			return childName
+		}
+	}
+
+	return name
+}
+
+// mergeByKey merges elements of the source branch into elements of the branch with the same value of the key field.
+func (mb *Branch) mergeByKey(src *Branch, keyField string, opts MergeOptions) error {
+	if keyField == "" {
+		return fmt.Errorf("key field of branch %q is not set", mb.FullKey)
+	}
+
+	positions := make(map[string]int, len(mb.Content))
+	for i := range mb.Content {
+		key, err := branchElementKey(mb, i, keyField)
+		if err != nil {
+			return err
+		}
+		positions[key] = i
+	}
+
+	for i, element := range src.Content {
+		key, err := branchElementKey(src, i, keyField)
+		if err != nil {
+			return err
+		}
+		if idx, ok := positions[key]; ok {
+			if err := mb.Content[idx].(*Tree).mergeTree(element.(*Tree), opts); err != nil {
+				return err
+			}
+			continue
+		}
+		positions[key] = len(mb.Content)
+		mb.AddOrReplaceDirectly(len(mb.Content), element)
+	}
+
+	return nil
+}
+
+// branchStrategy returns the strategy and the key field of the branch by its full key.
+func (o MergeOptions) branchStrategy(fullKey string) (BranchStrategy, string) {
+	if keyField, ok := o.BranchKeyFields[fullKey]; ok {
+		return BranchMergeByKey, keyField
+	}
+
+	return o.Branches, o.KeyField
+}
+
+// branchElementKey returns formatted value of the key field of the branch element.
+func branchElementKey(mb *Branch, idx int, keyField string) (string, error) {
+	element, ok := mb.Content[idx].(*Tree)
+	if !ok {
+		return "", fmt.Errorf("element #%d of branch %q is not a tree", idx+1, mb.FullKey)
+	}
+	leaf, ok := element.Content[element.childName(keyField)].(*Leaf)
+	if !ok {
+		return "", fmt.Errorf("field %q of element #%d of branch %q is not found or not a leaf", keyField, idx+1, mb.FullKey)
+	}
+	if leaf.Value == nil {
+		return "", fmt.Errorf("field %q of element #%d of branch %q is null", keyField, idx+1, mb.FullKey)
+	}
+
+	return FormatScalar(leaf.Value), nil
+}
@@ -0,0 +1,135 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyNaming converts names of nodes to parts of their full keys.
+type KeyNaming func(name string) string
+
+// Predefined key naming policies, SnakeCaseNaming is used by default.
+var (
+	SnakeCaseNaming KeyNaming = ToSnakeCase
+	VerbatimNaming  KeyNaming = func(name string) string { return name }
+	KebabCaseNaming KeyNaming = ToKebabCase
+	CamelCaseNaming KeyNaming = ToCamelCase
+)
+
+// Option configures a tree created by New.
+type Option func(mt *Tree)
+
+// WithKeyNaming sets policy of converting names to full keys for the tree and all its nodes.
+func WithKeyNaming(naming KeyNaming) Option {
+	return func(mt *Tree) {
+		mt.naming = naming
+	}
+}
+
+// KeyNamingByName returns predefined key naming policy by its name: snake, verbatim, kebab or camel.
+func KeyNamingByName(name string) (KeyNaming, error) {
+	switch name {
+	case "snake":
+		return SnakeCaseNaming, nil
+	case "verbatim":
+		return VerbatimNaming, nil
+	case "kebab":
+		return KebabCaseNaming, nil
+	case "camel":
+		return CamelCaseNaming, nil
+	default:
+		return nil, fmt.Errorf("unknown key naming %q", name)
+	}
+}
+
+// KeyNaming returns policy of converting names to full keys of the tree.
+func (mt *Tree) KeyNaming() KeyNaming {
+	if mt.naming == nil {
+		return SnakeCaseNaming
+	}
+
+	return mt.naming
+}
+
+// SetKeyNaming changes policy of converting names to full keys and recalculates full keys of all nodes.
+func (mt *Tree) SetKeyNaming(naming KeyNaming) {
+	mt.naming = naming
+	for _, name := range mt.Order {
+		mt.AddOrReplaceDirectly(name, mt.Content[name])
+	}
+}
+
+// ConvertName converts the name by the naming policy, nil policy is snake case.
+func (n KeyNaming) ConvertName(name string) string {
+	if n == nil {
+		n = SnakeCaseNaming
+	}
+
+	return n(name)
+}
+
+// makeFullKey is MakeFullKey with the naming policy, nil policy is snake case.
+// Separators in converted names are escaped, so every name stays a single part of the full key.
+func (n KeyNaming) makeFullKey(prefix, name string) string {
+	if n == nil {
+		return MakeFullKey(prefix, name)
+	}
+
+	name = EscapeKeyName(n(name))
+	if len(prefix) > 0 {
+		name = prefix + sep + name
+	}
+
+	return name
+}
+
+// ToKebabCase converts the name to snake case and replaces underscores by dashes.
+func ToKebabCase(str string) string {
+	return strings.ReplaceAll(ToSnakeCase(str), "_", "-")
+}
+
+// ToCamelCase converts the name to snake case and joins its words in lower camel case.
+func ToCamelCase(str string) string {
+	words := strings.Split(ToSnakeCase(str), "_")
+	for i := 1; i < len(words); i++ {
+		if len(words[i]) > 0 {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// makeMarkedFullKey is makeFullKey which keeps the marker at the beginning of the name as is,
+// so XML attribute "@id" and text "#text" don't collide with elements "id" and "text".
+func (n KeyNaming) makeMarkedFullKey(prefix, name, marker string) string {
+	if marker == "" || !strings.HasPrefix(name, marker) {
+		return n.makeFullKey(prefix, name)
+	}
+
+	name = EscapeKeyName(marker + n.ConvertName(name[len(marker):]))
+	if len(prefix) > 0 {
+		name = prefix + sep + name
+	}
+
+	return name
+}
+
+// makeNodeFullKey is makeFullKey of the node name, markers of XML leaves are kept.
+func (n KeyNaming) makeNodeFullKey(prefix, name string, m Marshalable) string {
+	if leaf, ok := m.(*Leaf); ok {
+		return n.makeMarkedFullKey(prefix, name, leaf.marker)
+	}
+
+	return n.makeFullKey(prefix, name)
+}
+
+// ConvertName converts the name of the leaf by the naming policy, nil policy is snake case.
+// The XML marker of the name is kept as is.
+func (ml *Leaf) ConvertName(naming KeyNaming) string {
+	if ml.marker == "" || !strings.HasPrefix(ml.Name, ml.marker) {
+		return naming.ConvertName(ml.Name)
+	}
+
+	return ml.marker + naming.ConvertName(ml.Name[len(ml.marker):])
+}
@@ -0,0 +1,203 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const propertiesSep = "."
+
+// UnmarshalProperties fills the tree from Java properties document. Dotted keys become nested sub-trees,
+// all values are kept as strings.
+func (mt *Tree) UnmarshalProperties(raw []byte) error {
+	mt.clearValues()
+
+	root := newFlatNode()
+	lines := strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// odd number of trailing backslashes continues the logical line
+		for endsWithContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if endsWithContinuation(line) {
+			line = line[:len(line)-1]
+		}
+
+		rawKey, rawValue := splitProperty(line)
+		key, err := unescapeProperty(rawKey)
+		if err != nil {
+			return fmt.Errorf("line %d: key: %w", i+1, err)
+		}
+		value, err := unescapeProperty(rawValue)
+		if err != nil {
+			return fmt.Errorf("line %d: value: %w", i+1, err)
+		}
+		path, err := splitFlatKey(key, propertiesSep)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		root.add(path, value)
+	}
+
+	return root.fill(mt)
+}
+
+// MarshalProperties writes leaves of the tree as Java properties with dotted keys. Null values are written as empty.
+func (mt *Tree) MarshalProperties() ([]byte, error) {
+	var buf bytes.Buffer
+	err := walkLeaves(mt, nil, func(path Path, leaf *Leaf) error {
+		for _, name := range path {
+			if strings.Contains(name, propertiesSep) {
+				return fmt.Errorf("key %q contains separator %q", name, propertiesSep)
+			}
+		}
+		buf.WriteString(escapeProperty(strings.Join(path, propertiesSep), true))
+		buf.WriteRune('=')
+		buf.WriteString(escapeProperty(flatValue(leaf), false))
+		buf.WriteRune('\n')
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// splitFlatKey splits the key by the separator, empty names are not allowed.
+func splitFlatKey(key, separator string) (Path, error) {
+	path := strings.Split(key, separator)
+	for _, name := range path {
+		if name == "" {
+			return nil, fmt.Errorf("key %q has empty part", key)
+		}
+	}
+
+	return path, nil
+}
+
+// flatValue returns the leaf value as a string, null becomes empty string.
+func flatValue(leaf *Leaf) string {
+	if leaf.Value == nil {
+		return ""
+	}
+
+	return FormatScalar(leaf.Value)
+}
+
+func endsWithContinuation(line string) bool {
+	slashes := len(line) - len(strings.TrimRight(line, `\`))
+
+	return slashes%2 == 1
+}
+
+// splitProperty splits the logical line to raw key and value. Key is terminated by unescaped '=', ':' or whitespace.
+func splitProperty(line string) (string, string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+
+	key, rest := line[:end], strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	return key, rest
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			break
+		}
+		switch s[i] {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\uXXXX escape in %q", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uXXXX escape in %q", s)
+			}
+			i += 4
+			r := rune(code)
+			// runes above U+FFFF are written as surrogate pairs
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) && i+7 <= len(s) {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			buf.WriteRune(r)
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// escapeProperty escapes special characters. Separators are escaped in keys, and leading space is escaped in values.
+// Non-ASCII characters are escaped as Properties.store does, because Properties.load reads files as ISO-8859-1.
+func escapeProperty(s string, isKey bool) string {
+	if isKey {
+		return propertyKeyEscapes.escape(s)
+	}
+
+	escaped := propertyValueEscapes.escape(s)
+	if strings.HasPrefix(escaped, " ") {
+		escaped = `\` + escaped
+	}
+
+	return escaped
+}
+
+var (
+	propertyValueEscapes = stringEscapes{
+		runes:   map[rune]string{'\\': `\\`, '\t': `\t`, '\n': `\n`, '\r': `\r`, '\f': `\f`},
+		unicode: true,
+		ascii:   true,
+	}
+	propertyKeyEscapes = stringEscapes{
+		runes:   mergeEscapes(propertyValueEscapes.runes, map[rune]string{'=': `\=`, ':': `\:`, '#': `\#`, '!': `\!`, ' ': `\ `}),
+		unicode: true,
+		ascii:   true,
+	}
+)
@@ -0,0 +1,207 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScalarType is a type of leaf value.
+type ScalarType string
+
+const (
+	StringType ScalarType = "string"
+	IntType    ScalarType = "int"
+	FloatType  ScalarType = "float"
+	BoolType   ScalarType = "bool"
+	NullType   ScalarType = "null"
+)
+
+// YAML tags of scalar types.
+const (
+	strTag   = "!!str"
+	intTag   = "!!int"
+	floatTag = "!!float"
+	boolTag  = "!!bool"
+	nullTag  = "!!null"
+	mergeTag = "!!merge"
+)
+
+// Type returns type of the leaf value. Values of leaves are always normalized to string, int64, float64, bool or nil.
+// Integers which don't fit int64 are kept as strings with the integer tag.
+func (ml *Leaf) Type() ScalarType {
+	switch ml.Value.(type) {
+	case nil:
+		return NullType
+	case bool:
+		return BoolType
+	case string:
+		if _, ok := ml.bigIntLiteral(); ok {
+			return IntType
+		}
+		return StringType
+	case int64:
+		return IntType
+	case float64:
+		return FloatType
+	default:
+		return StringType
+	}
+}
+
+// FormatScalar returns a scalar value as it's written in YAML documents.
+func FormatScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return ".inf"
+		case math.IsInf(v, -1):
+			return "-.inf"
+		case math.IsNaN(v):
+			return ".nan"
+		}
+		formatted := strconv.FormatFloat(v, 'g', -1, 64)
+		// without a point or an exponent the float becomes an integer after the next unmarshal
+		if !strings.ContainsAny(formatted, ".e") {
+			formatted += ".0"
+		}
+		return formatted
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// normalizeScalar converts a value to one of types which are supported by leaves.
+func normalizeScalar(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int64, float64:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return normalizeUint(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return normalizeUint(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		// integers out of int64 are kept exactly instead of rounding them to floats
+		if isIntegerLiteral(v.String()) {
+			if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				return normalizeUint(u)
+			}
+			return v.String()
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isIntegerLiteral reports whether the number literal has neither a point nor an exponent.
+func isIntegerLiteral(literal string) bool {
+	return literal != "" && !strings.ContainsAny(literal, ".eE")
+}
+
+// bigIntLiteral returns the literal of integer which doesn't fit int64, such integers are kept as strings with the tag.
+func (ml *Leaf) bigIntLiteral() (string, bool) {
+	literal, ok := ml.Value.(string)
+	if !ok || ml.Tag != intTag {
+		return "", false
+	}
+
+	return literal, true
+}
+
+func normalizeUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+
+	return int64(v)
+}
+
+// scalarFromYAML returns typed value of the scalar node according to its tag.
+// Values which can't be represented by supported types are kept as strings.
+func scalarFromYAML(node *yaml.Node) interface{} {
+	switch node.ShortTag() {
+	case nullTag:
+		return nil
+	case boolTag:
+		var v bool
+		if err := node.Decode(&v); err == nil {
+			return v
+		}
+	case intTag:
+		var v int64
+		if err := node.Decode(&v); err == nil {
+			return v
+		}
+	case floatTag:
+		var v float64
+		if err := node.Decode(&v); err == nil {
+			return v
+		}
+	}
+
+	return node.Value
+}
+
+// scalarTag returns YAML tag which is resolved for the value.
+func scalarTag(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return nullTag
+	case bool:
+		return boolTag
+	case int64:
+		return intTag
+	case float64:
+		return floatTag
+	default:
+		return strTag
+	}
+}
+
+// isCoreTag reports whether the tag is resolved to one of supported scalar types.
+func isCoreTag(tag string) bool {
+	switch tag {
+	case strTag, intTag, floatTag, boolTag, nullTag:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,311 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// TOML date-times are kept in leaves as strings with timestamp tag, so they are written back without quotes.
+const timestampTag = "!!timestamp"
+
+var tomlBareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// UnmarshalTOML fills the tree from TOML document. Tables become sub-trees, arrays and arrays of tables become branches.
+// Order of keys is restored from their positions in the document.
+func (mt *Tree) UnmarshalTOML(raw []byte) error {
+	mt.clearValues()
+
+	doc, err := toml.LoadBytes(raw)
+	if err != nil {
+		return fmt.Errorf("parse TOML: %w", err)
+	}
+
+	return mt.fillFromTOML(doc)
+}
+
+// MarshalTOML writes the tree as TOML document. Leaves and inline arrays of a table are written before its sub-tables,
+// branches of trees become arrays of tables.
+func (mt *Tree) MarshalTOML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, mt, nil, false); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mt *Tree) fillFromTOML(doc *toml.Tree) error {
+	keys := doc.Keys()
+	positions := make(map[string]toml.Position, len(keys))
+	for _, key := range keys {
+		positions[key] = tomlPosition(doc, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		pi, pj := positions[keys[i]], positions[keys[j]]
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Col < pj.Col
+	})
+
+	for _, key := range keys {
+		child, err := tomlToMarshalable(doc.GetPath([]string{key}), key, mt.FullKey)
+		if err != nil {
+			return fmt.Errorf("unmarshal %q: %w", key, err)
+		}
+		mt.AddOrReplaceDirectly(key, child)
+	}
+
+	return nil
+}
+
+// tomlPosition returns position of the key in the document. Inline tables have no positions,
+// so positions of their first keys are used.
+func tomlPosition(doc *toml.Tree, key string) toml.Position {
+	switch v := doc.GetPath([]string{key}).(type) {
+	case []*toml.Tree:
+		if len(v) > 0 {
+			return v[0].Position()
+		}
+	case *toml.Tree:
+		if v.Position().Invalid() {
+			var first toml.Position
+			for _, childKey := range v.Keys() {
+				pos := tomlPosition(v, childKey)
+				if first.Invalid() || pos.Line < first.Line || pos.Line == first.Line && pos.Col < first.Col {
+					first = pos
+				}
+			}
+			return first
+		}
+	}
+
+	return doc.GetPositionPath([]string{key})
+}
+
+func tomlToMarshalable(value interface{}, name, parentFullKey string) (Marshalable, error) {
+	switch v := value.(type) {
+	case *toml.Tree:
+		subTree := NewSubTree(name, parentFullKey)
+		return subTree, subTree.fillFromTOML(v)
+	case []*toml.Tree:
+		branch := NewBranch(name, parentFullKey)
+		for i, table := range v {
+			child, err := tomlToMarshalable(table, strconv.Itoa(i), branch.FullKey)
+			if err != nil {
+				return nil, err
+			}
+			branch.Add(child)
+		}
+		return branch, nil
+	case []interface{}:
+		branch := NewBranch(name, parentFullKey)
+		for i, item := range v {
+			child, err := tomlToMarshalable(item, strconv.Itoa(i), branch.FullKey)
+			if err != nil {
+				return nil, err
+			}
+			branch.Add(child)
+		}
+		return branch, nil
+	case time.Time:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = v.Format(time.RFC3339Nano)
+		leaf.Tag = timestampTag
+		return leaf, nil
+	case toml.LocalDate, toml.LocalDateTime, toml.LocalTime:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = fmt.Sprint(v)
+		leaf.Tag = timestampTag
+		return leaf, nil
+	case nil:
+		return nil, fmt.Errorf("value is not found")
+	default:
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = normalizeScalar(v)
+		return leaf, nil
+	}
+}
+
+// writeTOMLTable writes content of the tree. Header is written only for non-root tables.
+func writeTOMLTable(buf *bytes.Buffer, mt *Tree, path []string, isArrayElement bool) error {
+	// headers of tables which contain only sub-tables are redundant
+	if len(path) > 0 && (isArrayElement || mt.IsEmpty() || hasTOMLValues(mt)) {
+		if buf.Len() > 0 {
+			buf.WriteRune('\n')
+		}
+		header := formatTOMLPath(path)
+		if isArrayElement {
+			buf.WriteString("[[" + header + "]]\n")
+		} else {
+			buf.WriteString("[" + header + "]\n")
+		}
+	}
+
+	var tables []string
+	for _, name := range mt.Order {
+		switch item := mt.Content[name].(type) {
+		case *Tree:
+			tables = append(tables, name)
+			continue
+		case *Branch:
+			if isTOMLArrayOfTables(item) {
+				tables = append(tables, name)
+				continue
+			}
+		}
+
+		buf.WriteString(formatTOMLKey(name) + " = ")
+		if err := writeTOMLValue(buf, mt.Content[name]); err != nil {
+			return fmt.Errorf("marshal %q: %w", name, err)
+		}
+		buf.WriteRune('\n')
+	}
+
+	for _, name := range tables {
+		childPath := append(append([]string{}, path...), name)
+		switch item := mt.Content[name].(type) {
+		case *Tree:
+			if err := writeTOMLTable(buf, item, childPath, false); err != nil {
+				return err
+			}
+		case *Branch:
+			for _, element := range item.Content {
+				if err := writeTOMLTable(buf, element.(*Tree), childPath, true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeTOMLValue writes leaves and inline arrays and tables.
+func writeTOMLValue(buf *bytes.Buffer, m Marshalable) error {
+	switch item := m.(type) {
+	case *Tree:
+		buf.WriteRune('{')
+		for i, name := range item.Order {
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			buf.WriteString(" " + formatTOMLKey(name) + " = ")
+			if err := writeTOMLValue(buf, item.Content[name]); err != nil {
+				return fmt.Errorf("marshal %q: %w", name, err)
+			}
+		}
+		if len(item.Order) > 0 {
+			buf.WriteRune(' ')
+		}
+		buf.WriteRune('}')
+	case *Branch:
+		buf.WriteRune('[')
+		for i, child := range item.Content {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeTOMLValue(buf, child); err != nil {
+				return fmt.Errorf("marshal #%d: %w", i, err)
+			}
+		}
+		buf.WriteRune(']')
+	case *Leaf:
+		return writeTOMLScalar(buf, item)
+	default:
+		return fmt.Errorf("unsupported type %T", m)
+	}
+
+	return nil
+}
+
+func writeTOMLScalar(buf *bytes.Buffer, leaf *Leaf) error {
+	switch v := leaf.Value.(type) {
+	case nil:
+		return fmt.Errorf("TOML doesn't support null values")
+	case string:
+		if leaf.Tag == timestampTag {
+			buf.WriteString(v)
+		} else {
+			writeTOMLString(buf, v)
+		}
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			buf.WriteString("inf")
+		case math.IsInf(v, -1):
+			buf.WriteString("-inf")
+		case math.IsNaN(v):
+			buf.WriteString("nan")
+		default:
+			buf.WriteString(FormatScalar(v))
+		}
+	default:
+		buf.WriteString(FormatScalar(normalizeScalar(v)))
+	}
+
+	return nil
+}
+
+func hasTOMLValues(mt *Tree) bool {
+	for _, item := range mt.Content {
+		switch v := item.(type) {
+		case *Tree:
+			continue
+		case *Branch:
+			if isTOMLArrayOfTables(v) {
+				continue
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func isTOMLArrayOfTables(mb *Branch) bool {
+	if len(mb.Content) == 0 {
+		return false
+	}
+	for _, element := range mb.Content {
+		if _, ok := element.(*Tree); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatTOMLPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, name := range path {
+		keys[i] = formatTOMLKey(name)
+	}
+
+	return strings.Join(keys, ".")
+}
+
+func formatTOMLKey(name string) string {
+	if tomlBareKeyRegexp.MatchString(name) {
+		return name
+	}
+
+	var buf bytes.Buffer
+	writeTOMLString(&buf, name)
+
+	return buf.String()
+}
+
+var tomlEscapes = stringEscapes{runes: mergeEscapes(quotedEscapes, map[rune]string{'\b': `\b`, '\f': `\f`}), unicode: true}
+
+func writeTOMLString(buf *bytes.Buffer, s string) {
+	buf.WriteString(tomlEscapes.quote(s))
+}
@@ -31,33 +31,61 @@ type Tree struct {
 	Name         string
 	Order        []string
 	FullKey      string
+	Comments     Comments // YAML comments of the mapping, comments of the root are comments of the document
+	KeyComments  Comments // YAML comments of the key in the parent mapping
 	nestingLevel int
 	decoder      *json.Decoder
+	naming       KeyNaming
+	pos          Position
+	hclLabeled   bool // the tree is a type or a label of HCL blocks, its children are named by the next labels
 }
 
 type Branch struct {
 	Content      []Marshalable
 	Name         string
 	FullKey      string
+	Comments     Comments // YAML comments of the sequence
+	KeyComments  Comments // YAML comments of the key in the parent mapping
 	nestingLevel int
 	decoder      *json.Decoder
+	naming       KeyNaming
+	pos          Position
 }
 
 type Leaf struct {
 	Value            interface{}
+	Tag              string // YAML tag of the value, it's set only if it can't be resolved from the value
 	Name             string
 	FullKey          string
+	Comments         Comments // YAML comments of the scalar
+	KeyComments      Comments // YAML comments of the key in the parent mapping
 	decoder          *json.Decoder
 	nestingLevel     int
 	yamlMarshalStyle yaml.Style
+	naming           KeyNaming
+	pos              Position
+	marker           string // XML attribute prefix or text key which is kept in the full key as is
+	null             bool   // nil value is explicit null, otherwise the leaf is empty
+}
+
+// Comments are YAML comments which are attached to a node, they're kept with leading '#'.
+type Comments struct {
+	Head string
+	Line string
+	Foot string
 }
 
 type Path []string
 
-func New() *Tree {
-	return &Tree{
+func New(opts ...Option) *Tree {
+	mt := &Tree{
 		Content: make(map[string]Marshalable),
 	}
+	for _, opt := range opts {
+		opt(mt)
+	}
+
+	return mt
 }
 
 func NewSubTree(name, parentFullKey string) *Tree {
@@ -303,6 +331,7 @@ func (ml *Leaf) Delete(fullKey string) error {
 		return fmt.Errorf("incorrect key for leaf deletion: %q", fullKey)
 	}
 	ml.Value = nil
+	ml.null = false
 
 	return nil
 }
@@ -315,21 +344,24 @@ func (mt *Tree) AddOrReplaceDirectly(name string, value Marshalable) {
 	switch item := value.(type) {
 	case *Tree:
 		item.nestingLevel = mt.nestingLevel + 1
-		item.FullKey = MakeFullKey(mt.FullKey, name)
+		item.naming = mt.naming
+		item.FullKey = mt.naming.makeFullKey(mt.FullKey, name)
 		item.Name = name
 		for _, element := range item.Content {
 			item.AddOrReplaceDirectly(element.GetName(), element)
 		}
 	case *Branch:
 		item.nestingLevel = mt.nestingLevel + 1
-		item.FullKey = MakeFullKey(mt.FullKey, name)
+		item.naming = mt.naming
+		item.FullKey = mt.naming.makeFullKey(mt.FullKey, name)
 		item.Name = name
 		for idx, element := range item.Content {
 			item.AddOrReplaceDirectly(idx, element)
 		}
 	case *Leaf:
 		item.nestingLevel = mt.nestingLevel + 1
-		item.FullKey = MakeFullKey(mt.FullKey, name)
+		item.naming = mt.naming
+		item.FullKey = mt.naming.makeMarkedFullKey(mt.FullKey, name, item.marker)
 		item.Name = name
 	}
 
@@ -351,10 +383,11 @@ func (mb *Branch) AddOrReplaceDirectly(idx int, value Marshalable) {
 		newName = strconv.Itoa(idx)
 	}
 
-	itemNewFullKey := MakeFullKey(mb.FullKey, newName)
+	itemNewFullKey := mb.naming.makeFullKey(mb.FullKey, newName)
 	switch item := value.(type) {
 	case *Tree:
 		item.nestingLevel = mb.nestingLevel + 1
+		item.naming = mb.naming
 		item.FullKey = itemNewFullKey
 		item.Name = newName
 		for _, element := range item.Content {
@@ -362,6 +395,7 @@ func (mb *Branch) AddOrReplaceDirectly(idx int, value Marshalable) {
 		}
 	case *Branch:
 		item.nestingLevel = mb.nestingLevel + 1
+		item.naming = mb.naming
 		item.FullKey = itemNewFullKey
 		item.Name = newName
 		for subIdx, element := range item.Content {
@@ -369,6 +403,7 @@ func (mb *Branch) AddOrReplaceDirectly(idx int, value Marshalable) {
 		}
 	case *Leaf:
 		item.nestingLevel = mb.nestingLevel + 1
+		item.naming = mb.naming
 		item.FullKey = itemNewFullKey
 		item.Name = newName
 	}
@@ -396,6 +431,9 @@ func (mt *Tree) ShallowClone() *Tree {
 		FullKey:      mt.FullKey,
 		decoder:      mt.decoder,
 		nestingLevel: mt.nestingLevel,
+		naming:       mt.naming,
+		pos:          mt.pos,
+		hclLabeled:   mt.hclLabeled,
 	}
 
 	return newTree
@@ -422,8 +460,13 @@ func (mt *Tree) DeepClone() *Tree {
 		Name:         mt.Name,
 		Order:        newOrder,
 		FullKey:      mt.FullKey,
+		Comments:     mt.Comments,
+		KeyComments:  mt.KeyComments,
 		nestingLevel: mt.nestingLevel,
 		decoder:      mt.decoder,
+		naming:       mt.naming,
+		pos:          mt.pos,
+		hclLabeled:   mt.hclLabeled,
 	}
 
 	return newTree
@@ -446,35 +489,32 @@ func (mb *Branch) DeepClone() *Branch {
 		Content:      newContent,
 		Name:         mb.Name,
 		FullKey:      mb.FullKey,
+		Comments:     mb.Comments,
+		KeyComments:  mb.KeyComments,
 		nestingLevel: mb.nestingLevel,
 		decoder:      mb.decoder,
+		naming:       mb.naming,
+		pos:          mb.pos,
 	}
 
 	return newBranch
 }
 
 func (ml *Leaf) DeepClone() *Leaf {
-	var newValue string
-	switch oldValue := ml.Value.(type) {
-	case string:
-		newValue = oldValue
-	case rune:
-		newValue = string(oldValue)
-	case []byte:
-		newValue = string(oldValue)
-	case nil:
-		newValue = ""
-	default:
-		newValue = fmt.Sprint(oldValue)
-	}
-
 	return &Leaf{
-		Value:            newValue,
+		Value:            normalizeScalar(ml.Value),
+		Tag:              ml.Tag,
 		Name:             ml.Name,
 		FullKey:          ml.FullKey,
+		Comments:         ml.Comments,
+		KeyComments:      ml.KeyComments,
 		nestingLevel:     ml.nestingLevel,
 		decoder:          ml.decoder,
 		yamlMarshalStyle: ml.yamlMarshalStyle,
+		naming:           ml.naming,
+		pos:              ml.pos,
+		marker:           ml.marker,
+		null:             ml.null,
 	}
 }
 
@@ -554,23 +594,35 @@ func (ml *Leaf) GetNestingLevel() int {
 	return ml.nestingLevel
 }
 
+// IsEmpty reports whether the leaf has no value: it's deleted or its value isn't set. Explicit null isn't empty.
 func (ml *Leaf) IsEmpty() bool {
-	return ml.Value == nil
+	return ml.Value == nil && !ml.null
+}
+
+// IsNull reports whether the leaf value is explicit null.
+func (ml *Leaf) IsNull() bool {
+	return ml.Value == nil && ml.null
+}
+
+// SetValue sets the scalar value of the leaf, nil value is explicit null.
+func (ml *Leaf) SetValue(value interface{}) {
+	ml.Value = value
+	ml.null = value == nil
 }
 
 func (mt *Tree) ChangeName(name string, parentFullKey string) {
 	mt.Name = name
-	mt.changeFullKey(MakeFullKey(parentFullKey, name))
+	mt.changeFullKey(mt.naming.makeFullKey(parentFullKey, name))
 }
 
 func (mb *Branch) ChangeName(name string, parentFullKey string) {
 	mb.Name = name
-	mb.changeFullKey(MakeFullKey(parentFullKey, name))
+	mb.changeFullKey(mb.naming.makeFullKey(parentFullKey, name))
 }
 
 func (ml *Leaf) ChangeName(name string, parentFullKey string) {
 	ml.Name = name
-	ml.changeFullKey(MakeFullKey(parentFullKey, name))
+	ml.changeFullKey(ml.naming.makeMarkedFullKey(parentFullKey, name, ml.marker))
 }
 
 func initNestingLevel(parentFullKey string) int {
@@ -600,19 +652,20 @@ func (ml *Leaf) clearValues() {
 	if ml.Value != nil {
 		ml.Value = nil
 	}
+	ml.null = false
 }
 
 func (mt *Tree) changeFullKey(fullKey string) {
 	mt.FullKey = fullKey
 	for _, v := range mt.Content {
-		v.changeFullKey(MakeFullKey(fullKey, v.GetName()))
+		v.changeFullKey(mt.naming.makeFullKey(fullKey, v.GetName()))
 	}
 }
 
 func (mb *Branch) changeFullKey(fullKey string) {
 	mb.FullKey = fullKey
 	for _, v := range mb.Content {
-		v.changeFullKey(MakeFullKey(fullKey, v.GetName()))
+		v.changeFullKey(mb.naming.makeFullKey(fullKey, v.GetName()))
 	}
 }
 
@@ -3,9 +3,12 @@ package tree
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -14,8 +17,15 @@ import (
 type fileExtension string
 
 const (
-	extJSON fileExtension = "json"
-	extYAML fileExtension = "yaml"
+	extJSON       fileExtension = "json"
+	extYAML       fileExtension = "yaml"
+	extEDN        fileExtension = "edn"
+	extTOML       fileExtension = "toml"
+	extHCL        fileExtension = "hcl"
+	extProperties fileExtension = "properties"
+	extDotenv     fileExtension = "env"
+	extINI        fileExtension = "ini"
+	extXML        fileExtension = "xml"
 )
 
 type testName string
@@ -42,7 +52,7 @@ func TestTree_MarshalYAML(t *testing.T) {
 		fileName := fmt.Sprintf("%s.%s", tc.name, tc.ext)
 		testName := fmt.Sprintf("MarshalYAML test from fixture %d: %s", i+1, fileName)
 
-		filePath, err := filepath.Abs(filepath.Join("lib", "tree", "fixtures", fileName))
+		filePath, err := filepath.Abs(filepath.Join("fixtures", fileName))
 		if err != nil {
 			t.Fatalf("create file path of file %q: %v", fileName, err)
 		}
@@ -98,7 +108,7 @@ func TestTree_MarshalJSON(t *testing.T) {
 		fileName := fmt.Sprintf("%s.%s", tc.name, tc.ext)
 		testName := fmt.Sprintf("MarshalJSON test from fixture %d: %s", i+1, fileName)
 
-		filePath, err := filepath.Abs(filepath.Join("lib", "tree", "fixtures", fileName))
+		filePath, err := filepath.Abs(filepath.Join("fixtures", fileName))
 		if err != nil {
 			t.Fatalf("create file path of file %q: %v", fileName, err)
 		}
@@ -156,7 +166,7 @@ func TestTree_UnmarshalYAML(t *testing.T) {
 		fileName := fmt.Sprintf("%s.%s", tc.name, tc.ext)
 		testName := fmt.Sprintf("UnmarshalYAML test from fixture %d: %s", i+1, fileName)
 
-		filePath, err := filepath.Abs(filepath.Join("lib", "tree", "fixtures", fileName))
+		filePath, err := filepath.Abs(filepath.Join("fixtures", fileName))
 		if err != nil {
 			t.Fatalf("create file path of file %q: %v", fileName, err)
 		}
@@ -215,7 +225,7 @@ func TestTree_UnmarshalJSON(t *testing.T) {
 		fileName := fmt.Sprintf("%s.%s", tc.name, tc.ext)
 		testName := fmt.Sprintf("UnmarshalJSON test from fixture %d: %s", i+1, fileName)
 
-		filePath, err := filepath.Abs(filepath.Join("lib", "tree", "fixtures", fileName))
+		filePath, err := filepath.Abs(filepath.Join("fixtures", fileName))
 		if err != nil {
 			t.Fatalf("create file path of file %q: %v", fileName, err)
 		}
@@ -387,3 +397,966 @@ func TestTree_GetByFullKey(t *testing.T) {
 		}
 	}
 }
+
+func TestNewFromFlat(t *testing.T) {
+	pairs := map[string]string{
+		"welcome":                "to",
+		"hard_branch/0/name":     "SomeName",
+		"hard_branch/0/port":     "80",
+		"hard_branch/1":          "3",
+		"hard_branch/2/level2/0": "Two",
+		"hard_branch/2/level2/1": "2",
+		"tree/1":                 "one",
+	}
+
+	tree, err := NewFromFlat(pairs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		fullKey string
+		expType Marshalable
+	}{
+		{fullKey: "welcome", expType: &Leaf{}},
+		{fullKey: "hard_branch", expType: &Branch{}},
+		{fullKey: "hard_branch/0", expType: &Tree{}},
+		{fullKey: "hard_branch/0/port", expType: &Leaf{}},
+		{fullKey: "hard_branch/2/level2", expType: &Branch{}},
+		{fullKey: "hard_branch/2/level2/1", expType: &Leaf{}},
+		{fullKey: "tree", expType: &Tree{}},
+		{fullKey: "tree/1", expType: &Leaf{}},
+	}
+
+	for _, tc := range tests {
+		res, err := tree.GetByFullKey(tc.fullKey)
+		if err != nil {
+			t.Errorf("get %q: unexpected error: %v", tc.fullKey, err)
+			continue
+		}
+		if fmt.Sprintf("%T", res) != fmt.Sprintf("%T", tc.expType) {
+			t.Errorf("%q has type %T, expected %T", tc.fullKey, res, tc.expType)
+		}
+	}
+
+	if _, err := NewFromFlat(map[string]string{"a": "1", "a/b": "2"}); err == nil {
+		t.Errorf("expected error for key with both value and nested keys")
+	}
+}
+
+func TestLeaf_ScalarTypes(t *testing.T) {
+	yamlRaw := []byte(`str: "80"
+int: 80
+float: 1.0
+bool: true
+"null": null
+tagged: !!str 80
+date: 2021-01-07
+big: 18446744073709551615
+`)
+	jsonRaw := []byte(`{"str":"80","int":80,"float":1.0,"bool":true,"null":null,"big":18446744073709551615}`)
+
+	expTypes := map[string]ScalarType{
+		"str":    StringType,
+		"int":    IntType,
+		"float":  FloatType,
+		"bool":   BoolType,
+		"null":   NullType,
+		"tagged": StringType,
+		"date":   StringType,
+		"big":    IntType,
+	}
+
+	yamlTree := New()
+	if err := yaml.Unmarshal(yamlRaw, yamlTree); err != nil {
+		t.Fatalf("unmarshal YAML: %v", err)
+	}
+	jsonTree := New()
+	if err := json.Unmarshal(jsonRaw, jsonTree); err != nil {
+		t.Fatalf("unmarshal JSON: %v", err)
+	}
+
+	for name, m := range map[string]*Tree{"YAML": yamlTree.DeepClone(), "JSON": jsonTree.DeepClone()} {
+		for _, key := range m.Order {
+			leaf, ok := m.Content[key].(*Leaf)
+			if !ok {
+				t.Fatalf("%s: %q is not a leaf", name, key)
+			}
+			if leaf.Type() != expTypes[key] {
+				t.Errorf("%s: %q has type %q, expected %q", name, key, leaf.Type(), expTypes[key])
+			}
+			if leaf.IsNull() != (expTypes[key] == NullType) {
+				t.Errorf("%s: %q has unexpected null state", name, key)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&buf)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(yamlTree.DeepClone()); err != nil {
+		t.Fatalf("marshal YAML: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), yamlRaw) {
+		t.Errorf("YAML result %q != expectation %q", buf.String(), string(yamlRaw))
+	}
+
+	jsonRes, err := json.Marshal(jsonTree.DeepClone())
+	if err != nil {
+		t.Fatalf("marshal JSON: %v", err)
+	}
+	if !bytes.Equal(jsonRes, jsonRaw) {
+		t.Errorf("JSON result %q != expectation %q", string(jsonRes), string(jsonRaw))
+	}
+}
+
+func TestLeaf_Null(t *testing.T) {
+	docs := map[string]func(m *Tree) error{
+		"YAML":  func(m *Tree) error { return yaml.Unmarshal([]byte("a: ~\n"), m) },
+		"JSON":  func(m *Tree) error { return json.Unmarshal([]byte(`{"a":null}`), m) },
+		"JSON5": func(m *Tree) error { return m.UnmarshalJSON5([]byte(`{a: null}`)) },
+		"EDN":   func(m *Tree) error { return m.UnmarshalEDN([]byte(`{:a nil}`)) },
+		"HCL":   func(m *Tree) error { return m.UnmarshalHCL([]byte("a = null\n")) },
+	}
+	for name, unmarshal := range docs {
+		m := New()
+		if err := unmarshal(m); err != nil {
+			t.Fatalf("%s: unmarshaling error: %v", name, err)
+		}
+		leaf := m.Content["a"].(*Leaf)
+		if !leaf.IsNull() || leaf.IsEmpty() {
+			t.Errorf("%s: null leaf has IsNull %v and IsEmpty %v", name, leaf.IsNull(), leaf.IsEmpty())
+		}
+		if cloned := leaf.DeepClone(); !cloned.IsNull() {
+			t.Errorf("%s: null is lost by clone", name)
+		}
+
+		if err := leaf.Delete("a"); err != nil {
+			t.Fatalf("%s: delete: %v", name, err)
+		}
+		if leaf.IsNull() || !leaf.IsEmpty() {
+			t.Errorf("%s: deleted leaf has IsNull %v and IsEmpty %v", name, leaf.IsNull(), leaf.IsEmpty())
+		}
+	}
+
+	if leaf := NewLeaf("a", ""); leaf.IsNull() || !leaf.IsEmpty() {
+		t.Errorf("leaf without value has IsNull %v and IsEmpty %v", leaf.IsNull(), leaf.IsEmpty())
+	}
+}
+
+func TestTree_DeleteNull(t *testing.T) {
+	m := New()
+	if err := json.Unmarshal([]byte(`{"a":null,"b":{"c":null,"d":1},"e":[null,2]}`), m); err != nil {
+		t.Fatalf("unmarshal JSON: %v", err)
+	}
+
+	for _, fullKey := range []string{"a", "b/d", "e/1"} {
+		if err := m.Delete(fullKey); err != nil {
+			t.Fatalf("delete %q: %v", fullKey, err)
+		}
+	}
+
+	res, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal JSON: %v", err)
+	}
+	if exp := `{"b":{"c":null},"e":[null]}`; string(res) != exp {
+		t.Errorf("result %q != expectation %q", string(res), exp)
+	}
+
+	for _, fullKey := range []string{"b/c", "e/0"} {
+		if err := m.Delete(fullKey); err != nil {
+			t.Fatalf("delete %q: %v", fullKey, err)
+		}
+	}
+
+	res, err = json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal JSON: %v", err)
+	}
+	if exp := `{}`; string(res) != exp {
+		t.Errorf("result %q != expectation %q", string(res), exp)
+	}
+}
+
+func TestTree_RoundTrip(t *testing.T) {
+	tests := []struct {
+		ext       fileExtension
+		unmarshal func(m *Tree, raw []byte) error
+		marshal   func(m *Tree) ([]byte, error)
+		check     func(t *testing.T, m *Tree)
+	}{
+		{
+			ext:       extEDN,
+			unmarshal: (*Tree).UnmarshalEDN,
+			marshal:   func(m *Tree) ([]byte, error) { return m.MarshalEDN(2) },
+		},
+		{
+			ext:       extTOML,
+			unmarshal: (*Tree).UnmarshalTOML,
+			marshal:   (*Tree).MarshalTOML,
+			check: func(t *testing.T, m *Tree) {
+				if _, ok := m.Content["products"].(*Branch); !ok {
+					t.Errorf("array of tables is unmarshaled as %T", m.Content["products"])
+				}
+			},
+		},
+		{
+			ext:       extHCL,
+			unmarshal: (*Tree).UnmarshalHCL,
+			marshal:   func(m *Tree) ([]byte, error) { return m.MarshalHCL(2) },
+			check: func(t *testing.T, m *Tree) {
+				checks, err := m.GetByFullKey("service/http/web/check")
+				if err != nil {
+					t.Fatalf("get repeated blocks: %v", err)
+				}
+				if _, ok := checks.(*Branch); !ok {
+					t.Errorf("repeated blocks are unmarshaled as %T", checks)
+				}
+				ami, err := m.GetByFullKey("resource/aws_instance/web/ami")
+				if err != nil {
+					t.Fatalf("get attribute of labeled block: %v", err)
+				}
+				if v := ami.(*Leaf).Value; v != "ami-1" {
+					t.Errorf("unexpected attribute value %q", v)
+				}
+			},
+		},
+		{
+			ext:       extProperties,
+			unmarshal: (*Tree).UnmarshalProperties,
+			marshal:   (*Tree).MarshalProperties,
+			check: func(t *testing.T, m *Tree) {
+				if _, ok := m.Content["db"].(*Tree).Content["replicas"].(*Branch); !ok {
+					t.Errorf("indexed keys are unmarshaled as %T", m.Content["db"].(*Tree).Content["replicas"])
+				}
+			},
+		},
+		{
+			ext:       extDotenv,
+			unmarshal: (*Tree).UnmarshalDotenv,
+			marshal:   (*Tree).MarshalDotenv,
+			check: func(t *testing.T, m *Tree) {
+				secret, err := m.GetByFullKey("server/secret")
+				if err != nil {
+					t.Fatalf("get secret: %v", err)
+				}
+				if v := secret.(*Leaf).Value; v != `p@ss $word "quoted"` {
+					t.Errorf("unexpected unquoted value %q", v)
+				}
+			},
+		},
+		{
+			ext:       extINI,
+			unmarshal: (*Tree).UnmarshalINI,
+			marshal:   (*Tree).MarshalINI,
+			check: func(t *testing.T, m *Tree) {
+				expected := map[string]string{
+					"server/tls/cert":       `C:\certs\server.pem`,
+					"server/greeting":       " hello; world ",
+					"php/extension/1":       "mbstring # utf",
+					"php/session_save_path": "/tmp",
+					"a/b/c/deep":            "value",
+				}
+				for key, exp := range expected {
+					got, err := m.GetByFullKey(key)
+					if err != nil {
+						t.Fatalf("get %q: %v", key, err)
+					}
+					if got.(*Leaf).Value != exp {
+						t.Errorf("%q: %q != %q", key, got.(*Leaf).Value, exp)
+					}
+				}
+			},
+		},
+		{
+			ext: extXML,
+			unmarshal: func(m *Tree, raw []byte) error {
+				return m.UnmarshalXMLDocument(raw, DefaultXMLConfig)
+			},
+			marshal: func(m *Tree) ([]byte, error) { return m.MarshalXMLDocument(2, DefaultXMLConfig) },
+			check: func(t *testing.T, m *Tree) {
+				server := m.Content["config"].(*Tree).Content["server"].(*Tree)
+				if v := server.Content["@port"].(*Leaf).Value; v != "8080" {
+					t.Errorf("unexpected attribute value %q", v)
+				}
+				timeout := server.Content["timeout"].(*Tree)
+				if v := timeout.Content["#text"].(*Leaf).Value; v != "30" {
+					t.Errorf("unexpected text value %q", v)
+				}
+				replicas := m.Content["config"].(*Tree).Content["db"].(*Tree).Content["replica"]
+				if _, ok := replicas.(*Branch); !ok {
+					t.Errorf("repeated elements are unmarshaled as %T", replicas)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		fileName := fmt.Sprintf("%s.%s", testTreeHard, tc.ext)
+		filePath, err := filepath.Abs(filepath.Join("fixtures", fileName))
+		if err != nil {
+			t.Fatalf("create file path of file %q: %v", fileName, err)
+		}
+		expRaw, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			t.Fatalf("read file %q: %v", filePath, err)
+		}
+		t.Run(fileName, func(t *testing.T) {
+			m := New()
+			if err := tc.unmarshal(m, expRaw); err != nil {
+				t.Fatalf("unmarshaling error: %v", err)
+			}
+			if tc.check != nil {
+				tc.check(t, m)
+			}
+
+			res, err := tc.marshal(m)
+			if err != nil {
+				t.Fatalf("marshaling error: %v", err)
+			}
+			if !bytes.Equal(res, expRaw) {
+				t.Errorf("result %q != expectation %q", string(res), string(expRaw))
+			}
+		})
+	}
+}
+
+func TestTree_EscapedStrings(t *testing.T) {
+	values := []string{`quo"te`, `back\slash`, "multi\nline\r\ttab", "${tmpl} %{if}", "$HOME `cmd`", " leading", "ctrl\x01\x7f", "café Привет 世界 😀"}
+
+	tests := []struct {
+		ext       fileExtension
+		unmarshal func(m *Tree, raw []byte) error
+		marshal   func(m *Tree) ([]byte, error)
+		noUnicode bool
+	}{
+		{ext: extEDN, unmarshal: (*Tree).UnmarshalEDN, marshal: func(m *Tree) ([]byte, error) { return m.MarshalEDN(0) }},
+		{ext: extTOML, unmarshal: (*Tree).UnmarshalTOML, marshal: (*Tree).MarshalTOML},
+		{ext: extHCL, unmarshal: (*Tree).UnmarshalHCL, marshal: func(m *Tree) ([]byte, error) { return m.MarshalHCL(2) }},
+		{ext: extProperties, unmarshal: (*Tree).UnmarshalProperties, marshal: (*Tree).MarshalProperties},
+		{ext: extDotenv, unmarshal: (*Tree).UnmarshalDotenv, marshal: (*Tree).MarshalDotenv, noUnicode: true},
+		{ext: extINI, unmarshal: (*Tree).UnmarshalINI, marshal: (*Tree).MarshalINI, noUnicode: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.ext), func(t *testing.T) {
+			m := New()
+			for i, v := range values {
+				if tc.noUnicode && strings.ContainsAny(v, "\x01\x7f") {
+					continue
+				}
+				leaf := NewLeaf(fmt.Sprintf("key%d", i), "")
+				leaf.Value = v
+				m.AddOrReplaceDirectly(leaf.Name, leaf)
+			}
+
+			raw, err := tc.marshal(m)
+			if err != nil {
+				t.Fatalf("marshaling error: %v", err)
+			}
+			res := New()
+			if err := tc.unmarshal(res, raw); err != nil {
+				t.Fatalf("unmarshaling error of %q: %v", string(raw), err)
+			}
+			for _, name := range m.Order {
+				exp := m.Content[name].(*Leaf).Value
+				found, ok := res.Content[name].(*Leaf)
+				if !ok || found.Value != exp {
+					t.Errorf("value of %q %v != expectation %q in %q", name, found, exp, string(raw))
+				}
+			}
+		})
+	}
+}
+
+func TestTree_EDN(t *testing.T) {
+	m := New()
+	err := m.UnmarshalEDN([]byte(`; service config
+{:name "cimp", :mode :sync #_ :ignored
+ :hosts #{"a" "b"} :ports (80 443) :started #inst "2021-01-07T00:00:00Z" :limit nil}`))
+	if err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	res, err := m.MarshalEDN(0)
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	exp := `{:name "cimp" :mode :sync :hosts ["a" "b"] :ports [80 443] :started "2021-01-07T00:00:00Z" :limit nil}` + "\n"
+	if string(res) != exp {
+		t.Errorf("result %q != expectation %q", string(res), exp)
+	}
+	if mode := m.Content["mode"].(*Leaf); mode.Value != "sync" || mode.Tag != ednKeywordTag {
+		t.Errorf("keyword is unmarshaled as %q with tag %q", mode.Value, mode.Tag)
+	}
+
+	// strings which look like keywords stay strings
+	m = New()
+	if err := m.UnmarshalEDN([]byte(`{:a ":b"}`)); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if res, err = m.MarshalEDN(0); err != nil || string(res) != "{:a \":b\"}\n" {
+		t.Errorf("result %q of string with colon: %v", string(res), err)
+	}
+}
+
+func TestTree_Properties(t *testing.T) {
+	m := New()
+	raw := "# comment\n! comment\nkey : value \\\n    continued\nempty\nlist.0 first\nescaped=a\\:b\\u0041\n"
+	if err := m.UnmarshalProperties([]byte(raw)); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	expected := map[string]string{"key": "value continued", "empty": "", "list/0": "first", "escaped": "a:bA"}
+	for key, exp := range expected {
+		got, err := m.GetByFullKey(key)
+		if err != nil {
+			t.Fatalf("get %q: %v", key, err)
+		}
+		if got.(*Leaf).Value != exp {
+			t.Errorf("%q: %q != %q", key, got.(*Leaf).Value, exp)
+		}
+	}
+
+	// non-ASCII characters are escaped, because Java reads properties files as ISO-8859-1
+	m = New()
+	leaf := NewLeaf("name", "")
+	leaf.Value = "café Привет 😀"
+	m.AddOrReplaceDirectly(leaf.Name, leaf)
+	res, err := m.MarshalProperties()
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	if exp := `name=caf\u00E9 \u041F\u0440\u0438\u0432\u0435\u0442 \uD83D\uDE00` + "\n"; string(res) != exp {
+		t.Errorf("result %q != expectation %q", string(res), exp)
+	}
+	m = New()
+	if err := m.UnmarshalProperties(res); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if v := m.Content["name"].(*Leaf).Value; v != leaf.Value {
+		t.Errorf("value %q != expectation %q", v, leaf.Value)
+	}
+}
+
+func TestTree_Dotenv(t *testing.T) {
+	m := New()
+	raw := "# comment\nexport KEY=value # inline comment\nLITERAL='$not \\n escaped'\nMULTI=\"first\nsecond\"\n" +
+		"DB=x\nDB_HOST=y\n__PRIVATE=z\nA____B=w\n"
+	if err := m.UnmarshalDotenv([]byte(raw)); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	expected := map[string]string{
+		"key": "value", "literal": `$not \n escaped`, "multi": "first\nsecond",
+		"db": "x", "db_host": "y", "private": "z", "a_b": "w",
+	}
+	for key, exp := range expected {
+		got, err := m.GetByFullKey(key)
+		if err != nil {
+			t.Fatalf("get %q: %v", key, err)
+		}
+		if got.(*Leaf).Value != exp {
+			t.Errorf("%q: %q != %q", key, got.(*Leaf).Value, exp)
+		}
+	}
+
+	m = New()
+	if err := yaml.Unmarshal([]byte("db: {a__b: 1}\n"), m); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if _, err := m.MarshalDotenv(); err == nil {
+		t.Errorf("expected error for ambiguous name")
+	}
+}
+
+func TestTree_XML(t *testing.T) {
+	// attributes and text keep their markers in full keys
+	m := New()
+	if err := m.UnmarshalXMLDocument([]byte(`<root id="1"><id>2</id><text>3</text>4</root>`), DefaultXMLConfig); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	for fullKey, exp := range map[string]string{"root/@id": "1", "root/id": "2", "root/text": "3", "root/#text": "4"} {
+		found, err := m.GetByFullKey(fullKey)
+		if err != nil {
+			t.Fatalf("get %q: %v", fullKey, err)
+		}
+		if v := found.(*Leaf).Value; v != exp {
+			t.Errorf("value by %q %q != expectation %q", fullKey, v, exp)
+		}
+	}
+	if err := m.CheckKeyCollisions(nil); err != nil {
+		t.Errorf("unexpected collisions: %v", err)
+	}
+
+	cloned := m.DeepClone()
+	cloned.SetKeyNaming(KebabCaseNaming)
+	for _, fullKey := range []string{"root/@id", "root/#text"} {
+		if _, err := cloned.GetByFullKey(fullKey); err != nil {
+			t.Errorf("marker of %q is lost after clone and new naming: %v", fullKey, err)
+		}
+	}
+}
+
+func TestTree_UnmarshalJSON5(t *testing.T) {
+	filePath, err := filepath.Abs(filepath.Join("fixtures", "tree_hard.json5"))
+	if err != nil {
+		t.Fatalf("create file path: %v", err)
+	}
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read file %q: %v", filePath, err)
+	}
+	expPath, err := filepath.Abs(filepath.Join("fixtures", "tree_hard.json"))
+	if err != nil {
+		t.Fatalf("create file path: %v", err)
+	}
+	expRaw, err := ioutil.ReadFile(expPath)
+	if err != nil {
+		t.Fatalf("read file %q: %v", expPath, err)
+	}
+
+	m := New()
+	if err := m.UnmarshalJSON5(raw); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+
+	resBuf := bytes.Buffer{}
+	jsonEncoder := json.NewEncoder(&resBuf)
+	jsonEncoder.SetIndent("", "  ")
+	if err := jsonEncoder.Encode(m); err != nil {
+		t.Fatalf("encoding error: %v", err)
+	}
+	if !bytes.Equal(resBuf.Bytes(), expRaw) {
+		t.Errorf("result %q != expectation %q", resBuf.String(), string(expRaw))
+	}
+
+	nonFinite := New()
+	if err := nonFinite.UnmarshalJSON5([]byte(`{a: Infinity, b: [-Infinity, NaN], c: 1.5}`)); err != nil {
+		t.Fatalf("unmarshaling non-finite error: %v", err)
+	}
+	res, err := nonFinite.MarshalJSON5(0)
+	if err != nil {
+		t.Fatalf("marshaling non-finite error: %v", err)
+	}
+	if exp := "{\"a\":Infinity,\"b\":[-Infinity,NaN],\"c\":1.5}\n"; string(res) != exp {
+		t.Errorf("result %q != expectation %q", string(res), exp)
+	}
+
+	big := New()
+	if err := big.UnmarshalJSON5([]byte(`{a: 18446744073709551615, b: 1e400}`)); err != nil {
+		t.Fatalf("unmarshaling big numbers error: %v", err)
+	}
+	if res, err = big.MarshalJSON5(0); err != nil || string(res) != "{\"a\":18446744073709551615,\"b\":\"1e400\"}\n" {
+		t.Errorf("result %q of big numbers: %v", string(res), err)
+	}
+	res, err = m.MarshalJSON5(2)
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	if !bytes.Equal(res, expRaw) {
+		t.Errorf("indented result %q != expectation %q", string(res), string(expRaw))
+	}
+}
+
+func TestTree_UnmarshalYAMLDocuments(t *testing.T) {
+	raw := []byte(`---
+env: dev
+db:
+  host: localhost
+  port: 5432
+---
+---
+env: prod
+db:
+  host: db.prod
+hosts: [a, b]
+`)
+
+	m := New()
+	if err := m.UnmarshalYAMLDocuments(raw, ""); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	res, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	exp := `{"env":"prod","db":{"host":"db.prod","port":5432},"hosts":["a","b"]}`
+	if string(res) != exp {
+		t.Errorf("merged result %s != expectation %s", res, exp)
+	}
+
+	m = New()
+	if err := m.UnmarshalYAMLDocuments(raw, "env"); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	port, err := m.GetByFullKey("dev/db/port")
+	if err != nil {
+		t.Fatalf("get port: %v", err)
+	}
+	if v := port.(*Leaf).Value; v != int64(5432) {
+		t.Errorf("unexpected port %v", v)
+	}
+	if _, err := m.GetByFullKey("prod/hosts/1"); err != nil {
+		t.Errorf("get prod hosts: %v", err)
+	}
+
+	if err := New().UnmarshalYAMLDocuments(raw, "name"); err == nil {
+		t.Errorf("expected error for missing document key")
+	}
+}
+
+func TestTree_UnmarshalYAMLAliases(t *testing.T) {
+	raw := []byte(`
+defaults: &defaults
+  timeout: 30
+  retries: 3
+hosts: &hosts [a, b]
+limits: &limits
+  rps: 100
+service:
+  <<: [*defaults, *limits]
+  retries: 5
+  hosts: *hosts
+  name: &name web
+  alias: *name
+`)
+
+	m := New()
+	if err := yaml.Unmarshal(raw, m); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	res, err := json.Marshal(m.Content["service"])
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	exp := `{"timeout":30,"rps":100,"retries":5,"hosts":["a","b"],"name":"web","alias":"web"}`
+	if string(res) != exp {
+		t.Errorf("result %s != expectation %s", res, exp)
+	}
+
+	cyclic := []byte("a: &a\n  b: *a\n")
+	if err := yaml.Unmarshal(cyclic, New()); err == nil {
+		t.Errorf("expected error for cyclic alias")
+	}
+
+	bomb, err := ioutil.ReadFile(filepath.Join("fixtures", "alias_bomb.yaml"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if err := yaml.Unmarshal(bomb, New()); err == nil || !strings.Contains(err.Error(), "expanded by aliases") {
+		t.Errorf("expected error for alias bomb, got %v", err)
+	}
+}
+
+func TestTree_YAMLComments(t *testing.T) {
+	filePath, err := filepath.Abs(filepath.Join("fixtures", "tree_comments.yaml"))
+	if err != nil {
+		t.Fatalf("create file path: %v", err)
+	}
+	expRaw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read file %q: %v", filePath, err)
+	}
+
+	m := New()
+	if err := m.UnmarshalYAMLDocuments(expRaw, ""); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if c := m.Content["name"].(*Leaf).Comments.Line; c != "# line of name" {
+		t.Errorf("unexpected line comment %q", c)
+	}
+
+	doc, err := m.MarshalYAMLDocument()
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	resBuf := bytes.Buffer{}
+	yamlEncoder := yaml.NewEncoder(&resBuf)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(doc); err != nil {
+		t.Fatalf("encoding error: %v", err)
+	}
+	if !bytes.Equal(resBuf.Bytes(), expRaw) {
+		t.Errorf("result %q != expectation %q", resBuf.String(), string(expRaw))
+	}
+}
+
+func TestTree_KeyNaming(t *testing.T) {
+	raw := []byte(`
+HardBranch:
+  server.http-port: 8080
+  items:
+    - maxConns: 10
+`)
+
+	cases := []struct {
+		naming  KeyNaming
+		expKeys []string
+	}{
+		{nil, []string{"hard_branch/server_http_port", "hard_branch/items/0/max_conns"}},
+		{VerbatimNaming, []string{"HardBranch/server.http-port", "HardBranch/items/0/maxConns"}},
+		{KebabCaseNaming, []string{"hard-branch/server-http-port", "hard-branch/items/0/max-conns"}},
+		{CamelCaseNaming, []string{"hardBranch/serverHttpPort", "hardBranch/items/0/maxConns"}},
+		{strings.ToUpper, []string{"HARDBRANCH/SERVER.HTTP-PORT", "HARDBRANCH/ITEMS/0/MAXCONNS"}},
+	}
+	for _, c := range cases {
+		m := New(WithKeyNaming(c.naming))
+		if err := yaml.Unmarshal(raw, m); err != nil {
+			t.Fatalf("unmarshaling error: %v", err)
+		}
+		for _, key := range c.expKeys {
+			if _, err := m.GetByFullKey(key); err != nil {
+				t.Errorf("key %q is not found: %v", key, err)
+			}
+		}
+	}
+
+	m := New()
+	if err := yaml.Unmarshal(raw, m); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	m.SetKeyNaming(VerbatimNaming)
+	if _, err := m.GetByFullKey("HardBranch/items/0/maxConns"); err != nil {
+		t.Errorf("full keys aren't changed by new naming: %v", err)
+	}
+	if _, err := KeyNamingByName("pascal"); err == nil {
+		t.Errorf("expected error for unknown naming")
+	}
+	// leading symbols are markers of XML documents only, other formats drop them as before
+	m = New()
+	if err := yaml.Unmarshal([]byte("\"@timestamp\": 1\n$ref: 2\n\"#x\": 3\n"), m); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	for _, key := range []string{"timestamp", "ref", "x"} {
+		if _, err := m.GetByFullKey(key); err != nil {
+			t.Errorf("key %q is not found: %v", key, err)
+		}
+	}
+}
+
+func TestTree_KeyCollisions(t *testing.T) {
+	yamlRaw := []byte(`
+fooBar: 1
+nested:
+  - a-b: 1
+    a_b: 2
+foo_bar: 2
+foo-bar: 3
+`)
+	err := yaml.Unmarshal(yamlRaw, New())
+	var collisionErr *CollisionError
+	if !errors.As(err, &collisionErr) {
+		t.Fatalf("expected collision error, got %v", err)
+	}
+	expCollisions := []KeyCollision{
+		{
+			FullKey:   "foo_bar",
+			Names:     []string{"fooBar", "foo_bar", "foo-bar"},
+			Positions: []Position{{Line: 2, Column: 1}, {Line: 6, Column: 1}, {Line: 7, Column: 1}},
+		},
+		{
+			FullKey:   "nested/0/a_b",
+			Names:     []string{"a-b", "a_b"},
+			Positions: []Position{{Line: 4, Column: 5}, {Line: 5, Column: 5}},
+		},
+	}
+	if !reflect.DeepEqual(collisionErr.Collisions, expCollisions) {
+		t.Errorf("result %+v != expectation %+v", collisionErr.Collisions, expCollisions)
+	}
+
+	err = json.Unmarshal([]byte(`{"fooBar": 1, "foo_bar": 2}`), New())
+	if !errors.As(err, &collisionErr) {
+		t.Fatalf("expected collision error, got %v", err)
+	}
+	expPositions := []Position{{Offset: 9}, {Offset: 23}}
+	if !reflect.DeepEqual(collisionErr.Collisions[0].Positions, expPositions) {
+		t.Errorf("result %+v != expectation %+v", collisionErr.Collisions[0].Positions, expPositions)
+	}
+
+	if err := yaml.Unmarshal(yamlRaw, New(WithKeyNaming(VerbatimNaming))); err != nil {
+		t.Errorf("unexpected error with verbatim naming: %v", err)
+	}
+}
+
+func TestTree_EscapedKeys(t *testing.T) {
+	m := New(WithKeyNaming(VerbatimNaming))
+	if err := yaml.Unmarshal([]byte("\"a/b\": 1\na: {b: 2}\n\"50%2F\": 3\n"), m); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+
+	cases := map[string]interface{}{"a%2Fb": int64(1), "a/b": int64(2), "50%252F": int64(3)}
+	for fullKey, expValue := range cases {
+		found, err := m.GetByFullKey(fullKey)
+		if err != nil {
+			t.Fatalf("get %q: %v", fullKey, err)
+		}
+		if leaf, ok := found.(*Leaf); !ok || leaf.Value != expValue {
+			t.Errorf("value by %q %v != expectation %v", fullKey, found, expValue)
+		}
+	}
+
+	if err := m.Delete("a%2Fb"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := m.Content["a/b"]; ok {
+		t.Errorf("escaped key isn't deleted")
+	}
+	if _, err := m.GetByFullKey("a/b"); err != nil {
+		t.Errorf("nested key is deleted: %v", err)
+	}
+
+	if path := SplitFullKey("x/a%2Fb/50%252F"); !reflect.DeepEqual(path, Path{"x", "a/b", "50%2F"}) {
+		t.Errorf("unexpected path %q", path)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := New()
+	if err := yaml.Unmarshal([]byte(`
+name: base
+log: {level: info, format: json}
+hosts: [a, b]
+servers:
+  - {name: a, port: 1}
+  - {name: b, port: 2}
+limits: 10
+`), base); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	overlay := New()
+	if err := yaml.Unmarshal([]byte(`
+log: {level: debug}
+hosts: [c]
+servers:
+  - {name: b, port: 3}
+  - {name: c, port: 4}
+limits: {rps: 5}
+`), overlay); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+
+	cases := []struct {
+		opts MergeOptions
+		exp  string
+	}{
+		{
+			MergeOptions{},
+			`{"name":"base","log":{"level":"debug","format":"json"},"hosts":["c"],` +
+				`"servers":[{"name":"b","port":3},{"name":"c","port":4}],"limits":{"rps":5}}`,
+		},
+		{
+			MergeOptions{Branches: BranchAppend},
+			`{"name":"base","log":{"level":"debug","format":"json"},"hosts":["a","b","c"],` +
+				`"servers":[{"name":"a","port":1},{"name":"b","port":2},{"name":"b","port":3},{"name":"c","port":4}],"limits":{"rps":5}}`,
+		},
+		{
+			MergeOptions{BranchKeyFields: map[string]string{"servers": "name"}},
+			`{"name":"base","log":{"level":"debug","format":"json"},"hosts":["c"],` +
+				`"servers":[{"name":"a","port":1},{"name":"b","port":3},{"name":"c","port":4}],"limits":{"rps":5}}`,
+		},
+	}
+	for _, c := range cases {
+		merged, err := Merge(base, overlay, c.opts)
+		if err != nil {
+			t.Fatalf("merge with %+v: %v", c.opts, err)
+		}
+		res, err := json.Marshal(merged)
+		if err != nil {
+			t.Fatalf("marshaling error: %v", err)
+		}
+		if string(res) != c.exp {
+			t.Errorf("result of merge with %+v %s != expectation %s", c.opts, res, c.exp)
+		}
+		if _, err := merged.GetByFullKey("servers/1/port"); err != nil {
+			t.Errorf("full keys of merged branch aren't updated: %v", err)
+		}
+	}
+
+	if res, _ := json.Marshal(base.Content["servers"]); string(res) != `[{"name":"a","port":1},{"name":"b","port":2}]` {
+		t.Errorf("base tree is changed: %s", res)
+	}
+	if _, err := Merge(base, overlay, MergeOptions{Branches: BranchMergeByKey, KeyField: "port"}); err == nil {
+		t.Errorf("expected error for branch of leaves merged by key")
+	}
+	// names of different styles are matched by full keys
+	camel, snake := New(), New()
+	if err := yaml.Unmarshal([]byte("db: {maxConns: 10}\nservers: [{serverName: a, port: 1}]\n"), camel); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte("db: {max_conns: 20}\nservers: [{server_name: a, port: 2}]\n"), snake); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	merged, err := Merge(camel, snake, MergeOptions{Branches: BranchMergeByKey, KeyField: "serverName"})
+	if err != nil {
+		t.Fatalf("merge of different names: %v", err)
+	}
+	res, err := json.Marshal(merged)
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	if exp := `{"db":{"maxConns":20},"servers":[{"serverName":"a","port":2}]}`; string(res) != exp {
+		t.Errorf("result of merge of different names %s != expectation %s", res, exp)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, b := New(), New()
+	if err := yaml.Unmarshal([]byte(`
+name: cimp
+port: 80
+debug: false
+hosts: [a, b, c]
+log: {level: info}
+db: {host: localhost}
+timeout: 5
+`), a); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte(`
+name: cimp
+port: 8080
+debug: "false"
+hosts: [a, x]
+log: {level: info, format: json}
+timeout: {read: 5}
+cache: {ttl: 10}
+`), b); err != nil {
+		t.Fatalf("unmarshaling error: %v", err)
+	}
+
+	type change struct {
+		Type    ChangeType
+		FullKey string
+		OldKind NodeKind
+		NewKind NodeKind
+	}
+	var res []change
+	for _, c := range Diff(a, b) {
+		res = append(res, change{c.Type, c.FullKey, c.OldKind, c.NewKind})
+	}
+	exp := []change{
+		{ChangeModified, "port", LeafKind, LeafKind},
+		{ChangeTypeChanged, "debug", LeafKind, LeafKind},
+		{ChangeResized, "hosts", BranchKind, BranchKind},
+		{ChangeModified, "hosts/1", LeafKind, LeafKind},
+		{ChangeRemoved, "hosts/2", LeafKind, ""},
+		{ChangeAdded, "log/format", "", LeafKind},
+		{ChangeRemoved, "db", TreeKind, ""},
+		{ChangeTypeChanged, "timeout", LeafKind, TreeKind},
+		{ChangeAdded, "cache", "", TreeKind},
+	}
+	if !reflect.DeepEqual(res, exp) {
+		t.Errorf("result %+v != expectation %+v", res, exp)
+	}
+
+	if changes := Diff(a, a.DeepClone()); len(changes) > 0 {
+		t.Errorf("unexpected changes of equal trees: %+v", changes)
+	}
+
+	raw, err := json.Marshal(Diff(a, b)[0])
+	if err != nil {
+		t.Fatalf("marshaling error: %v", err)
+	}
+	if expRaw := `{"type":"modified","key":"port","old_kind":"leaf","new_kind":"leaf","old":80,"new":8080}`; string(raw) != expRaw {
+		t.Errorf("result %s != expectation %s", raw, expRaw)
+	}
+}
@@ -0,0 +1,262 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// XMLConfig defines how attributes and text of elements which have attributes or children are named in trees.
+// The attribute prefix and the text key of imported documents are kept in full keys as is,
+// so "@id" and "#text" don't collide with elements "id" and "text".
+type XMLConfig struct {
+	AttrPrefix string
+	TextKey    string
+}
+
+// DefaultXMLConfig names attribute "id" as "@id" and text of element as "#text".
+var DefaultXMLConfig = XMLConfig{AttrPrefix: "@", TextKey: "#text"}
+
+var xmlNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*(:[A-Za-z_][A-Za-z0-9_.-]*)?$`)
+
+// xmlElement is an intermediate element which keeps all children before grouping of repeated ones.
+type xmlElement struct {
+	name     string
+	attrs    []xml.Attr
+	text     strings.Builder
+	children []*xmlElement
+}
+
+// UnmarshalXMLDocument fills the tree from XML document. The root element becomes the single key of the tree,
+// elements with only text become leaves, repeated sibling elements become branches.
+// Namespaces of elements and attributes are dropped, so documents which use them aren't written back equivalently.
+// Declarations "xmlns" are kept as attributes. All values are kept as strings.
+func (mt *Tree) UnmarshalXMLDocument(raw []byte, cfg XMLConfig) error {
+	mt.clearValues()
+
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	var root *xmlElement
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parse XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if root != nil {
+				return fmt.Errorf("parse XML: document has several root elements")
+			}
+			if root, err = readXMLElement(dec, t); err != nil {
+				return fmt.Errorf("parse XML: %w", err)
+			}
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return fmt.Errorf("parse XML: text outside of the root element")
+			}
+		}
+	}
+	if root == nil {
+		return fmt.Errorf("parse XML: root element is not found")
+	}
+
+	mt.AddOrReplaceDirectly(root.name, root.toMarshalable(root.name, mt.FullKey, cfg))
+
+	return nil
+}
+
+// MarshalXMLDocument writes the tree with the single key as XML document. Keys with the attribute prefix become
+// attributes, branches become repeated elements. If indent is zero, the document is written in one line.
+// Names are written without namespaces, see UnmarshalXMLDocument.
+func (mt *Tree) MarshalXMLDocument(indent int, cfg XMLConfig) ([]byte, error) {
+	if len(mt.Order) != 1 {
+		return nil, fmt.Errorf("XML document must have one root element, got %d keys", len(mt.Order))
+	}
+	name := mt.Order[0]
+	if _, ok := mt.Content[name].(*Branch); ok {
+		return nil, fmt.Errorf("root element %q can't be an array", name)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := writeXMLElement(&buf, name, mt.Content[name], indent, 0, cfg); err != nil {
+		return nil, err
+	}
+	buf.WriteRune('\n')
+
+	return buf.Bytes(), nil
+}
+
+func readXMLElement(dec *xml.Decoder, start xml.StartElement) (*xmlElement, error) {
+	el := &xmlElement{name: start.Name.Local}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" {
+			attr.Name.Local = "xmlns:" + attr.Name.Local
+		}
+		el.attrs = append(el.attrs, attr)
+	}
+
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := readXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			el.children = append(el.children, child)
+		case xml.CharData:
+			el.text.Write(t)
+		case xml.EndElement:
+			return el, nil
+		}
+	}
+}
+
+func (el *xmlElement) toMarshalable(name, parentFullKey string, cfg XMLConfig) Marshalable {
+	text := el.text.String()
+	if len(el.attrs) == 0 && len(el.children) == 0 {
+		leaf := NewLeaf(name, parentFullKey)
+		leaf.Value = text
+		return leaf
+	}
+
+	subTree := NewSubTree(name, parentFullKey)
+	for _, attr := range el.attrs {
+		attrName := cfg.AttrPrefix + attr.Name.Local
+		leaf := NewLeaf(attrName, subTree.FullKey)
+		leaf.Value = attr.Value
+		leaf.marker = cfg.AttrPrefix
+		subTree.AddOrReplaceDirectly(attrName, leaf)
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		leaf := NewLeaf(cfg.TextKey, subTree.FullKey)
+		leaf.Value = text
+		leaf.marker = cfg.TextKey
+		subTree.AddOrReplaceDirectly(cfg.TextKey, leaf)
+	}
+
+	// siblings with the same name are grouped at the place of the first one
+	var (
+		order  []string
+		groups = make(map[string][]*xmlElement)
+	)
+	for _, child := range el.children {
+		if _, ok := groups[child.name]; !ok {
+			order = append(order, child.name)
+		}
+		groups[child.name] = append(groups[child.name], child)
+	}
+	for _, childName := range order {
+		group := groups[childName]
+		if len(group) == 1 {
+			subTree.AddOrReplaceDirectly(childName, group[0].toMarshalable(childName, subTree.FullKey, cfg))
+			continue
+		}
+		branch := NewBranch(childName, subTree.FullKey)
+		for i, child := range group {
+			branch.Add(child.toMarshalable(strconv.Itoa(i), branch.FullKey, cfg))
+		}
+		subTree.AddOrReplaceDirectly(childName, branch)
+	}
+
+	return subTree
+}
+
+func writeXMLElement(buf *bytes.Buffer, name string, m Marshalable, indent, level int, cfg XMLConfig) error {
+	if !xmlNameRegexp.MatchString(name) {
+		return fmt.Errorf("%q is not valid XML name", name)
+	}
+	if indent > 0 && level > 0 {
+		buf.WriteString("\n" + strings.Repeat(" ", indent*level))
+	}
+
+	switch item := m.(type) {
+	case *Leaf:
+		buf.WriteString("<" + name + ">")
+		escapeXML(buf, flatValue(item))
+		buf.WriteString("</" + name + ">")
+	case *Tree:
+		buf.WriteString("<" + name)
+		var (
+			text     *Leaf
+			children []string
+		)
+		for _, childName := range item.Order {
+			child := item.Content[childName]
+			leaf, isLeaf := child.(*Leaf)
+			switch {
+			case isLeaf && childName == cfg.TextKey:
+				text = leaf
+			case isLeaf && cfg.AttrPrefix != "" && strings.HasPrefix(childName, cfg.AttrPrefix):
+				attrName := strings.TrimPrefix(childName, cfg.AttrPrefix)
+				if !xmlNameRegexp.MatchString(attrName) {
+					return fmt.Errorf("%q is not valid XML attribute name", attrName)
+				}
+				buf.WriteString(" " + attrName + `="`)
+				escapeXML(buf, flatValue(leaf))
+				buf.WriteRune('"')
+			default:
+				children = append(children, childName)
+			}
+		}
+		if text == nil && len(children) == 0 {
+			buf.WriteString("/>")
+			return nil
+		}
+		buf.WriteRune('>')
+		if text != nil {
+			escapeXML(buf, flatValue(text))
+		}
+
+		for _, childName := range children {
+			var err error
+			if branch, ok := item.Content[childName].(*Branch); ok {
+				err = writeXMLRepeated(buf, childName, branch, indent, level+1, cfg)
+			} else {
+				err = writeXMLElement(buf, childName, item.Content[childName], indent, level+1, cfg)
+			}
+			if err != nil {
+				return fmt.Errorf("marshal %q: %w", name, err)
+			}
+		}
+		if indent > 0 && len(children) > 0 {
+			buf.WriteString("\n" + strings.Repeat(" ", indent*level))
+		}
+		buf.WriteString("</" + name + ">")
+	default:
+		return fmt.Errorf("unsupported type %T", m)
+	}
+
+	return nil
+}
+
+// writeXMLRepeated writes elements of the branch as siblings with the same name.
+func writeXMLRepeated(buf *bytes.Buffer, name string, mb *Branch, indent, level int, cfg XMLConfig) error {
+	for i, element := range mb.Content {
+		if _, ok := element.(*Branch); ok {
+			return fmt.Errorf("element #%d of %q: nested arrays are not supported", i, name)
+		}
+		if err := writeXMLElement(buf, name, element, indent, level, cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func escapeXML(buf *bytes.Buffer, s string) {
+	// EscapeText returns errors of the writer only, bytes.Buffer never fails
+	_ = xml.EscapeText(buf, []byte(s))
+}
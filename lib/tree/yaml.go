@@ -1,16 +1,94 @@
 package tree
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 
 	"gopkg.in/yaml.v3"
 )
 
+// UnmarshalYAMLDocuments fills the tree from multi-document YAML stream. If the document key is empty,
+// documents are merged in order and later ones override earlier. Otherwise every document is merged
+// into the sub-tree named by the value of the document key.
+func (mt *Tree) UnmarshalYAMLDocuments(raw []byte, documentKey string) error {
+	docs, err := SplitYAMLDocuments(raw, WithKeyNaming(mt.naming))
+	if err != nil {
+		return err
+	}
+
+	mt.clearValues()
+	for i, doc := range docs {
+		if documentKey == "" {
+			if i == 0 {
+				mt.Comments = doc.Comments
+			}
+			mt.merge(doc)
+			continue
+		}
+
+		leaf, ok := doc.Content[documentKey].(*Leaf)
+		if !ok || leaf.Value == nil {
+			return fmt.Errorf("document #%d: scalar value of document key %q is not found", i+1, documentKey)
+		}
+		// comments of the document become comments of its key
+		doc.KeyComments, doc.Comments = doc.Comments, Comments{}
+		wrapper := New(WithKeyNaming(mt.naming))
+		wrapper.AddOrReplaceDirectly(FormatScalar(leaf.Value), doc)
+		mt.merge(wrapper)
+	}
+
+	return nil
+}
+
+// SplitYAMLDocuments reads every document of multi-document YAML stream into its own tree, empty documents are skipped.
+func SplitYAMLDocuments(raw []byte, opts ...Option) ([]*Tree, error) {
+	var docs []*Tree
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	for i := 1; ; i++ {
+		var node yaml.Node
+		if err := dec.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, fmt.Errorf("decode document #%d: %w", i, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].ShortTag() == nullTag {
+			continue
+		}
+
+		doc := New(opts...)
+		if err := doc.UnmarshalYAML(node.Content[0]); err != nil {
+			return nil, fmt.Errorf("unmarshal document #%d: %w", i, err)
+		}
+		if doc.Comments == (Comments{}) {
+			doc.Comments = commentsFromYAML(&node)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// UnmarshalYAML fills the tree from the mapping node. Aliases and merge keys are resolved to copies of anchored nodes.
+// Sibling keys which are converted to the same full keys are reported by *CollisionError.
 func (mt *Tree) UnmarshalYAML(node *yaml.Node) error {
+	expanded, err := newYAMLExpander(node).expand(node)
+	if err != nil {
+		return err
+	}
+	if err := mt.unmarshalYAML(expanded); err != nil {
+		return err
+	}
+
+	return mt.CheckKeyCollisions(nil)
+}
+
+func (mt *Tree) unmarshalYAML(node *yaml.Node) error {
 	if node.Kind != yaml.MappingNode {
 		return fmt.Errorf("yaml node should have Mapping kind for unmarshal, not %v", node.Kind)
 	}
 	mt.clearValues()
+	mt.Comments = commentsFromYAML(node)
 
 	// Every even item for name only, odd items - for values
 	for i := 0; i < len(node.Content); i += 2 {
@@ -23,18 +101,24 @@ func (mt *Tree) UnmarshalYAML(node *yaml.Node) error {
 			if err := leaf.UnmarshalYAML(curNode); err != nil {
 				return fmt.Errorf("unmarshal leaf %q: %w", curKey, err)
 			}
+			leaf.KeyComments = commentsFromYAML(node.Content[i])
+			leaf.pos = yamlPosition(node.Content[i])
 			mt.AddOrReplaceDirectly(curKey, leaf)
 		case yaml.MappingNode:
 			childTree := NewSubTree(curKey, mt.FullKey)
-			if err := childTree.UnmarshalYAML(curNode); err != nil {
+			if err := childTree.unmarshalYAML(curNode); err != nil {
 				return fmt.Errorf("unmarshal sub-tree %q: %w", curKey, err)
 			}
+			childTree.KeyComments = commentsFromYAML(node.Content[i])
+			childTree.pos = yamlPosition(node.Content[i])
 			mt.AddOrReplaceDirectly(curKey, childTree)
 		case yaml.SequenceNode:
 			branch := NewBranch(curKey, mt.FullKey)
-			if err := branch.UnmarshalYAML(curNode); err != nil {
+			if err := branch.unmarshalYAML(curNode); err != nil {
 				return fmt.Errorf("unmarshal branch %q: %w", curKey, err)
 			}
+			branch.KeyComments = commentsFromYAML(node.Content[i])
+			branch.pos = yamlPosition(node.Content[i])
 			mt.AddOrReplaceDirectly(curKey, branch)
 		default:
 			return fmt.Errorf("unprocessable content type of %q: %v", curKey, curNode.Kind)
@@ -44,11 +128,22 @@ func (mt *Tree) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// UnmarshalYAML fills the branch from the sequence node. Aliases and merge keys are resolved to copies of anchored nodes.
 func (mb *Branch) UnmarshalYAML(node *yaml.Node) error {
+	expanded, err := newYAMLExpander(node).expand(node)
+	if err != nil {
+		return err
+	}
+
+	return mb.unmarshalYAML(expanded)
+}
+
+func (mb *Branch) unmarshalYAML(node *yaml.Node) error {
 	if node.Kind != yaml.SequenceNode {
 		return fmt.Errorf("yaml node should have Sequence kind for unmarshal as branch, not %v", node.Kind)
 	}
 	mb.clearValues()
+	mb.Comments = commentsFromYAML(node)
 
 	for i, curNode := range node.Content {
 		curKey := fmt.Sprint(i)
@@ -56,17 +151,19 @@ func (mb *Branch) UnmarshalYAML(node *yaml.Node) error {
 		switch curNode.Kind {
 		case yaml.ScalarNode:
 			leaf := NewLeaf(curKey, mb.FullKey)
-			leaf.Value = curNode.Value
+			if err := leaf.UnmarshalYAML(curNode); err != nil {
+				return fmt.Errorf("unmarshal leaf #%s: %w", curKey, err)
+			}
 			mb.Add(leaf)
 		case yaml.MappingNode:
 			tree := NewSubTree(curKey, mb.FullKey)
-			if err := tree.UnmarshalYAML(curNode); err != nil {
+			if err := tree.unmarshalYAML(curNode); err != nil {
 				return fmt.Errorf("unmarshal %q: %w", curKey, err)
 			}
 			mb.Add(tree)
 		case yaml.SequenceNode:
 			childBranch := NewBranch(curKey, mb.FullKey)
-			if err := childBranch.UnmarshalYAML(curNode); err != nil {
+			if err := childBranch.unmarshalYAML(curNode); err != nil {
 				return fmt.Errorf("unamrshal child branch #%s: %w", curKey, err)
 			}
 			mb.Add(childBranch)
@@ -78,6 +175,21 @@ func (mb *Branch) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// MarshalYAMLDocument returns the document node of the tree, comments of the tree are written as comments of the document.
+func (mt *Tree) MarshalYAMLDocument() (*yaml.Node, error) {
+	marshaled, err := mt.MarshalYAML()
+	if err != nil {
+		return nil, err
+	}
+	node := marshaled.(*yaml.Node)
+	Comments{}.toYAML(node)
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}
+	mt.Comments.toYAML(doc)
+
+	return doc, nil
+}
+
 func (mt *Tree) MarshalYAML() (interface{}, error) {
 	var content []*yaml.Node
 	for _, leafName := range mt.Order {
@@ -95,21 +207,36 @@ func (mt *Tree) MarshalYAML() (interface{}, error) {
 		if !ok {
 			return nil, fmt.Errorf("MarshalYAML return %T insread of yaml.Node", leafNode)
 		}
+		keyComments(mt.Content[leafName]).toYAML(keyNode)
 		content = append(content, keyNode, leafNode)
 	}
 
-	return &yaml.Node{
+	node := &yaml.Node{
 		Kind:    yaml.MappingNode,
 		Value:   mt.Name,
 		Content: content,
-	}, nil
+	}
+	mt.Comments.toYAML(node)
+
+	return node, nil
 }
 
 func (ml *Leaf) UnmarshalYAML(node *yaml.Node) error {
 	ml.clearValues()
 	switch node.Kind {
 	case yaml.ScalarNode:
-		ml.Value = node.Value
+		ml.SetValue(scalarFromYAML(node))
+		ml.Comments = commentsFromYAML(node)
+		ml.yamlMarshalStyle = node.Style
+		ml.Tag = ""
+		// tags of supported types are resolved from values, others should be kept to not lose them
+		if node.Style&yaml.TaggedStyle != 0 || !isCoreTag(node.ShortTag()) {
+			ml.Tag = node.Tag
+		}
+		// integers which don't fit int64 are kept as strings, the tag keeps their type
+		if _, isString := ml.Value.(string); isString && node.ShortTag() == intTag {
+			ml.Tag = intTag
+		}
 	default:
 		return fmt.Errorf("unprocessable content type `%v` for leaf %q", node.Kind, ml.FullKey)
 	}
@@ -121,7 +248,8 @@ func (mb *Branch) MarshalYAML() (interface{}, error) {
 	var nodeContent []*yaml.Node
 	isAllLeafs := true
 	for i, item := range mb.Content {
-		if _, ok := item.(*Leaf); isAllLeafs && !ok {
+		// comments can't be written inside of flow sequences
+		if leaf, ok := item.(*Leaf); isAllLeafs && (!ok || leaf.Comments != Comments{}) {
 			isAllLeafs = false
 		}
 		marshaled, err := item.MarshalYAML()
@@ -141,21 +269,185 @@ func (mb *Branch) MarshalYAML() (interface{}, error) {
 		style = yaml.FlowStyle
 	}
 
-	return &yaml.Node{
+	node := &yaml.Node{
 		Kind:    yaml.SequenceNode,
 		Content: nodeContent,
 		Style:   style,
-	}, nil
+	}
+	mb.Comments.toYAML(node)
+
+	return node, nil
 }
 
 func (ml *Leaf) MarshalYAML() (interface{}, error) {
-	if ml.yamlMarshalStyle == 0 {
-		ml.yamlMarshalStyle = yaml.TaggedStyle
+	tag := ml.Tag
+	if len(tag) == 0 {
+		tag = scalarTag(ml.Value)
 	}
 
-	return &yaml.Node{
+	node := &yaml.Node{
 		Kind:  yaml.ScalarNode,
 		Style: ml.yamlMarshalStyle,
-		Value: fmt.Sprint(ml.Value),
-	}, nil
+		Tag:   tag,
+		Value: FormatScalar(ml.Value),
+	}
+	ml.Comments.toYAML(node)
+
+	return node, nil
+}
+
+// Limits of expansion of aliases. Documents may grow by aliases up to the ratio of their own size,
+// but small ones are allowed to reach the minimal limit and large ones can't exceed the maximal one.
+const (
+	yamlExpansionRatio    = 100
+	yamlExpansionMinLimit = 10000
+	yamlExpansionMaxLimit = 1000000
+)
+
+// yamlExpander expands aliases of one node and limits count of expanded nodes to stop alias bombs.
+type yamlExpander struct {
+	// path contains mappings and sequences which are expanded now to detect cycles
+	path  map[*yaml.Node]bool
+	count int
+	limit int
+}
+
+func newYAMLExpander(root *yaml.Node) *yamlExpander {
+	limit := countYAMLNodes(root) * yamlExpansionRatio
+	if limit < yamlExpansionMinLimit {
+		limit = yamlExpansionMinLimit
+	}
+	if limit > yamlExpansionMaxLimit {
+		limit = yamlExpansionMaxLimit
+	}
+
+	return &yamlExpander{path: make(map[*yaml.Node]bool), limit: limit}
+}
+
+// expand returns copy of the node where aliases are replaced by anchored nodes and merge keys
+// are replaced by keys of merged mappings. Explicit keys override merged ones, earlier merged mappings override later.
+func (e *yamlExpander) expand(node *yaml.Node) (*yaml.Node, error) {
+	if node.Kind == yaml.AliasNode {
+		if e.path[node.Alias] {
+			return nil, fmt.Errorf("line %d: alias %q refers to its own anchor", node.Line, node.Value)
+		}
+		return e.expand(node.Alias)
+	}
+
+	e.count++
+	if e.count > e.limit {
+		return nil, fmt.Errorf("line %d: document is expanded by aliases to more than %d nodes", node.Line, e.limit)
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode, yaml.DocumentNode:
+	default:
+		return node, nil
+	}
+
+	e.path[node] = true
+	defer delete(e.path, node)
+
+	expanded := *node
+	expanded.Content = make([]*yaml.Node, 0, len(node.Content))
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			expandedChild, err := e.expand(child)
+			if err != nil {
+				return nil, err
+			}
+			expanded.Content = append(expanded.Content, expandedChild)
+		}
+		return &expanded, nil
+	}
+
+	explicit := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isYAMLMergeKey(node.Content[i]) {
+			explicit[resolveYAMLAlias(node.Content[i]).Value] = true
+		}
+	}
+
+	added := make(map[string]bool, len(explicit))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value, err := e.expand(node.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if !isYAMLMergeKey(node.Content[i]) {
+			key := resolveYAMLAlias(node.Content[i])
+			expanded.Content = append(expanded.Content, key, value)
+			continue
+		}
+
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for _, mapping := range merged {
+			if mapping.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: merge key value must be a mapping or a sequence of mappings", node.Content[i].Line)
+			}
+			for j := 0; j+1 < len(mapping.Content); j += 2 {
+				name := mapping.Content[j].Value
+				if explicit[name] || added[name] {
+					continue
+				}
+				added[name] = true
+				expanded.Content = append(expanded.Content, mapping.Content[j], mapping.Content[j+1])
+			}
+		}
+	}
+
+	return &expanded, nil
+}
+
+// countYAMLNodes returns count of nodes of the document without expansion of aliases.
+func countYAMLNodes(node *yaml.Node) int {
+	count := 1
+	for _, child := range node.Content {
+		count += countYAMLNodes(child)
+	}
+
+	return count
+}
+
+func isYAMLMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == mergeTag
+}
+
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	return node
+}
+
+func commentsFromYAML(node *yaml.Node) Comments {
+	return Comments{Head: node.HeadComment, Line: node.LineComment, Foot: node.FootComment}
+}
+
+func yamlPosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+func (c Comments) toYAML(node *yaml.Node) {
+	node.HeadComment = c.Head
+	node.LineComment = c.Line
+	node.FootComment = c.Foot
+}
+
+// keyComments returns comments of the key of the node in the parent mapping.
+func keyComments(m Marshalable) Comments {
+	switch item := m.(type) {
+	case *Tree:
+		return item.KeyComments
+	case *Branch:
+		return item.KeyComments
+	case *Leaf:
+		return item.KeyComments
+	default:
+		return Comments{}
+	}
 }